@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -24,9 +25,15 @@ func GetFlagOrEnv(cmd *cobra.Command, flags map[string]bool) map[string]string {
 			envName = "GHMLFS_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
 		}
 
-		// Check all possible sources
-		flagVal, _ := cmd.Flags().GetString(flagName)
+		// Check all possible sources. The command's own flag is read
+		// directly, of any type, since commands sharing a key overwrite
+		// each other's viper bindings. Flag defaults only apply when the
+		// environment doesn't set a value.
 		envVal := viper.GetString(envName)
+		flagVal := ""
+		if flag := cmd.Flags().Lookup(flagName); flag != nil && (flag.Changed || envVal == "") {
+			flagVal = flag.Value.String()
+		}
 
 		value := ""
 		if flagVal != "" {
@@ -35,6 +42,11 @@ func GetFlagOrEnv(cmd *cobra.Command, flags map[string]bool) map[string]string {
 			value = envVal
 		}
 
+		// Tokens are masked in all output from here on
+		if strings.HasSuffix(envName, "_TOKEN") {
+			common.RegisterSecrets(value)
+		}
+
 		if value != "" {
 			// Store both versions to ensure consistency
 			viper.Set(flagName, value)
@@ -54,19 +66,22 @@ func GetFlagOrEnv(cmd *cobra.Command, flags map[string]bool) map[string]string {
 }
 
 func ShowConnectionStatus(actionType string) {
-	var endpoint string
+	var endpoints []string
 
 	switch actionType {
 	case "export", "pull":
-		endpoint = "source-hostname"
-	case "sync":
-		endpoint = "target-hostname"
+		endpoints = []string{"source-hostname"}
+	case "sync", "verify":
+		endpoints = []string{"target-hostname"}
+	case "compare", "transfer", "migrate", "sync-direct":
+		endpoints = []string{"source-hostname", "target-hostname"}
 	}
 
-	hostname := getNormalizedEndpoint(endpoint)
-
-	fmt.Println(getHostnameMessage(hostname))
-	fmt.Println(getProxyStatus())
+	for _, endpoint := range endpoints {
+		hostname := getNormalizedEndpoint(endpoint)
+		common.Println(getHostnameMessage(hostname))
+	}
+	common.Println(getProxyStatus())
 }
 
 func getNormalizedEndpoint(key string) string {
@@ -78,6 +93,8 @@ func getNormalizedEndpoint(key string) string {
 		hostname = strings.TrimSuffix(hostname, "/")
 		hostname = fmt.Sprintf("https://%s/api/v3", hostname)
 		viper.Set(key, hostname)
+		// Commands read the prefixed key, keep it in step
+		viper.Set("GHMLFS_"+strings.ToUpper(strings.ReplaceAll(key, "-", "_")), hostname)
 	}
 	return hostname
 }
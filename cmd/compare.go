@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/compare"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Compares LFS content between source and target repositories",
+	Long:  "Compares the LFS pointers of the same refs in source and target repositories and reports the differences",
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMLFS_FILE":                true,
+			"GHMLFS_SOURCE_HOSTNAME":     false,
+			"GHMLFS_SOURCE_TOKEN":        true,
+			"GHMLFS_TARGET_HOSTNAME":     false,
+			"GHMLFS_TARGET_ORGANIZATION": false,
+			"GHMLFS_TARGET_TOKEN":        true,
+			"GHMLFS_WORK_DIR":            true,
+			"GHMLFS_WORKERS":             false,
+			"GHMLFS_MAPPING_FILE":        false,
+			"GHMLFS_COMPARE_FORMAT":      false,
+			"GHMLFS_COMPARE_OUTPUT":      false,
+		})
+
+		ShowConnectionStatus("compare")
+		if err := compare.CompareFromCSV(); err != nil {
+			common.Printf("failed to compare repositories: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	compareCmd.Flags().StringP("file", "f", "", "Exported LFS repos file path, csv format (required)")
+	compareCmd.Flags().String("source-hostname", "", "Source GitHub Enterprise Server hostname URL (optional)")
+	compareCmd.Flags().String("source-token", "", "Source GitHub token with repo scope (required)")
+	compareCmd.Flags().String("target-hostname", "", "Target GitHub Enterprise Server hostname URL (optional)")
+	compareCmd.Flags().StringP("target-organization", "o", "", "Organization (required unless every repository is mapped)")
+	compareCmd.Flags().String("target-token", "", "Target GitHub token with repo scope (required)")
+	compareCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required)")
+	compareCmd.Flags().IntP("workers", "w", 1, "Number of concurrent GIT workers to use")
+	compareCmd.Flags().StringP("mapping-file", "m", "", "Repository mapping file, source_org/source_repo,target_org/target_repo per line (optional)")
+	compareCmd.Flags().String("compare-format", "csv", "Report format: csv or json")
+	compareCmd.Flags().String("compare-output", "", "Report file path (default lfs_compare.<format>)")
+
+	viper.BindPFlag("GHMLFS_FILE", compareCmd.Flags().Lookup("file"))
+	viper.BindPFlag("GHMLFS_SOURCE_HOSTNAME", compareCmd.Flags().Lookup("source-hostname"))
+	viper.BindPFlag("GHMLFS_SOURCE_TOKEN", compareCmd.Flags().Lookup("source-token"))
+	viper.BindPFlag("GHMLFS_TARGET_HOSTNAME", compareCmd.Flags().Lookup("target-hostname"))
+	viper.BindPFlag("GHMLFS_TARGET_ORGANIZATION", compareCmd.Flags().Lookup("target-organization"))
+	viper.BindPFlag("GHMLFS_TARGET_TOKEN", compareCmd.Flags().Lookup("target-token"))
+	viper.BindPFlag("GHMLFS_WORK_DIR", compareCmd.Flags().Lookup("work-dir"))
+	viper.BindPFlag("GHMLFS_WORKERS", compareCmd.Flags().Lookup("workers"))
+	viper.BindPFlag("GHMLFS_MAPPING_FILE", compareCmd.Flags().Lookup("mapping-file"))
+	viper.BindPFlag("GHMLFS_COMPARE_FORMAT", compareCmd.Flags().Lookup("compare-format"))
+	viper.BindPFlag("GHMLFS_COMPARE_OUTPUT", compareCmd.Flags().Lookup("compare-output"))
+}
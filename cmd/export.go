@@ -1,8 +1,7 @@
 package cmd
 
 import (
-	"fmt"
-
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
 	"github.com/mona-actions/gh-migrate-lfs/pkg/export"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -18,11 +17,12 @@ var exportCmd = &cobra.Command{
 			"GHMLFS_SOURCE_ORGANIZATION": true,
 			"GHMLFS_SOURCE_TOKEN":        true,
 			"GHMLFS_SEARCH_DEPTH":        false,
+			"GHMLFS_WORK_DIR":            false,
 		})
 
 		ShowConnectionStatus("export")
 		if err := export.ExportLFSRepos(); err != nil {
-			fmt.Printf("failed to export lfs: %v\n", err)
+			common.Printf("failed to export lfs: %v\n", err)
 		}
 	},
 }
@@ -32,9 +32,11 @@ func init() {
 	exportCmd.Flags().StringP("source-organization", "o", "", "Organization (required)")
 	exportCmd.Flags().StringP("source-token", "t", "", "GitHub token (required)")
 	exportCmd.Flags().StringP("search-depth", "s", "", "Search depth for .gitattributes file")
+	exportCmd.Flags().StringP("work-dir", "d", "", "Working directory to keep the migration state in (optional)")
 
 	viper.BindPFlag("GHMLFS_SOURCE_HOSTNAME", exportCmd.Flags().Lookup("source-hostname"))
 	viper.BindPFlag("GHMLFS_SOURCE_ORGANIZATION", exportCmd.Flags().Lookup("source-organization"))
 	viper.BindPFlag("GHMLFS_SOURCE_TOKEN", exportCmd.Flags().Lookup("source-token"))
 	viper.BindPFlag("GHMLFS_SEARCH_DEPTH", exportCmd.Flags().Lookup("search-depth"))
+	viper.BindPFlag("GHMLFS_WORK_DIR", exportCmd.Flags().Lookup("work-dir"))
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/migrate"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Runs export, pull and sync end-to-end",
+	Long:  "Exports the repositories with LFS content of an organization, pulls them and syncs them to the target in one run",
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMLFS_SOURCE_ORGANIZATION": true,
+			"GHMLFS_SOURCE_HOSTNAME":     false,
+			"GHMLFS_SOURCE_TOKEN":        true,
+			"GHMLFS_TARGET_ORGANIZATION": false,
+			"GHMLFS_TARGET_HOSTNAME":     false,
+			"GHMLFS_TARGET_TOKEN":        true,
+			"GHMLFS_WORK_DIR":            true,
+			"GHMLFS_WORKERS":             false,
+			"GHMLFS_SEARCH_DEPTH":        false,
+			"GHMLFS_MAPPING_FILE":        false,
+			"GHMLFS_CREATE_MISSING":      false,
+			"GHMLFS_ALLOW_INCOMPLETE":    false,
+			"GHMLFS_VERIFY":              false,
+			"GHMLFS_ON_FAILURE":          false,
+		})
+
+		ShowConnectionStatus("migrate")
+		if err := migrate.MigrateLFS(); err != nil {
+			common.Printf("failed to migrate repositories: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	migrateCmd.Flags().String("source-organization", "", "Source organization (required)")
+	migrateCmd.Flags().String("source-hostname", "", "Source GitHub Enterprise Server hostname URL (optional)")
+	migrateCmd.Flags().String("source-token", "", "Source GitHub token with repo scope (required)")
+	migrateCmd.Flags().StringP("target-organization", "o", "", "Target organization (required unless every repository is mapped)")
+	migrateCmd.Flags().String("target-hostname", "", "Target GitHub Enterprise Server hostname URL (optional)")
+	migrateCmd.Flags().String("target-token", "", "Target GitHub token with repo scope (required)")
+	migrateCmd.Flags().StringP("work-dir", "d", "", "Working directory for cloned repositories and the migration state (required)")
+	migrateCmd.Flags().IntP("workers", "w", 1, "Number of concurrent GIT workers to use")
+	migrateCmd.Flags().StringP("search-depth", "s", "", "Search depth for .gitattributes file")
+	migrateCmd.Flags().StringP("mapping-file", "m", "", "Repository mapping file, source_org/source_repo,target_org/target_repo per line (optional)")
+	migrateCmd.Flags().Bool("create-missing", false, "Create target repositories that don't exist, using the source visibility, description and default branch")
+	migrateCmd.Flags().Bool("allow-incomplete", false, "Push repositories with corrupt or missing local LFS objects, leaving those objects out")
+	migrateCmd.Flags().Bool("verify", false, "Verify that every LFS object exists on the target after syncing each repository")
+	migrateCmd.Flags().String("on-failure", "stop", "What to do when repositories fail a stage: stop, skip them, or continue with all")
+
+	viper.BindPFlag("GHMLFS_SOURCE_ORGANIZATION", migrateCmd.Flags().Lookup("source-organization"))
+	viper.BindPFlag("GHMLFS_SOURCE_HOSTNAME", migrateCmd.Flags().Lookup("source-hostname"))
+	viper.BindPFlag("GHMLFS_SOURCE_TOKEN", migrateCmd.Flags().Lookup("source-token"))
+	viper.BindPFlag("GHMLFS_TARGET_ORGANIZATION", migrateCmd.Flags().Lookup("target-organization"))
+	viper.BindPFlag("GHMLFS_TARGET_HOSTNAME", migrateCmd.Flags().Lookup("target-hostname"))
+	viper.BindPFlag("GHMLFS_TARGET_TOKEN", migrateCmd.Flags().Lookup("target-token"))
+	viper.BindPFlag("GHMLFS_WORK_DIR", migrateCmd.Flags().Lookup("work-dir"))
+	viper.BindPFlag("GHMLFS_WORKERS", migrateCmd.Flags().Lookup("workers"))
+	viper.BindPFlag("GHMLFS_SEARCH_DEPTH", migrateCmd.Flags().Lookup("search-depth"))
+	viper.BindPFlag("GHMLFS_MAPPING_FILE", migrateCmd.Flags().Lookup("mapping-file"))
+	viper.BindPFlag("GHMLFS_CREATE_MISSING", migrateCmd.Flags().Lookup("create-missing"))
+	viper.BindPFlag("GHMLFS_ALLOW_INCOMPLETE", migrateCmd.Flags().Lookup("allow-incomplete"))
+	viper.BindPFlag("GHMLFS_VERIFY", migrateCmd.Flags().Lookup("verify"))
+	viper.BindPFlag("GHMLFS_ON_FAILURE", migrateCmd.Flags().Lookup("on-failure"))
+}
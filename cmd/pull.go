@@ -1,8 +1,7 @@
 package cmd
 
 import (
-	"fmt"
-
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
 	"github.com/mona-actions/gh-migrate-lfs/pkg/pull"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -19,11 +18,14 @@ var pullCmd = &cobra.Command{
 			"GHMLFS_SOURCE_TOKEN":    true,
 			"GHMLFS_WORK_DIR":        true,
 			"GHMLFS_WORKERS":         false,
+			"GHMLFS_SHARED_STORE":    false,
+			"GHMLFS_DISK_CHECK":      false,
+			"GHMLFS_MIN_FREE_SPACE":  false,
 		})
 
 		ShowConnectionStatus("export")
 		if err := pull.PullLFSFromCSV(); err != nil {
-			fmt.Printf("failed to export variables: %v\n", err)
+			common.Printf("failed to export variables: %v\n", err)
 		}
 	},
 }
@@ -34,10 +36,17 @@ func init() {
 	pullCmd.Flags().StringP("source-token", "t", "", "GitHub token with repo scope (required)")
 	pullCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required)")
 	pullCmd.Flags().IntP("workers", "w", 1, "Number of concurrent GIT workers to use")
+	pullCmd.Flags().String("shared-store", "", "Directory for LFS objects shared by all repositories, each object is downloaded once (optional)")
 
 	viper.BindPFlag("GHMLFS_FILE", pullCmd.Flags().Lookup("file"))
 	viper.BindPFlag("GHMLFS_SOURCE_HOSTNAME", pullCmd.Flags().Lookup("source-hostname"))
 	viper.BindPFlag("GHMLFS_SOURCE_TOKEN", pullCmd.Flags().Lookup("source-token"))
 	viper.BindPFlag("GHMLFS_WORK_DIR", pullCmd.Flags().Lookup("work-dir"))
 	viper.BindPFlag("GHMLFS_WORKERS", pullCmd.Flags().Lookup("workers"))
+	pullCmd.Flags().String("disk-check", "refuse", "What to do when the estimated pull size exceeds free space: refuse, warn or off")
+	pullCmd.Flags().String("min-free-space", "", "Free space to keep on the work dir, workers pause below it, e.g. 20GiB (optional)")
+
+	viper.BindPFlag("GHMLFS_SHARED_STORE", pullCmd.Flags().Lookup("shared-store"))
+	viper.BindPFlag("GHMLFS_DISK_CHECK", pullCmd.Flags().Lookup("disk-check"))
+	viper.BindPFlag("GHMLFS_MIN_FREE_SPACE", pullCmd.Flags().Lookup("min-free-space"))
 }
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/report"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generates an HTML and Markdown migration report",
+	Long:  "Generates a shareable HTML page and Markdown summary from the migration state and results reports",
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMLFS_WORK_DIR":      false,
+			"GHMLFS_RESULTS":       false,
+			"GHMLFS_REPORT_OUTPUT": false,
+			"GHMLFS_REPORT_TOP":    false,
+		})
+
+		if err := report.GenerateReport(); err != nil {
+			common.Printf("failed to generate report: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	reportCmd.Flags().StringP("work-dir", "d", "", "Working directory with the migration state and results reports")
+	reportCmd.Flags().String("results", "", "Comma-separated JSON results reports to include (optional)")
+	reportCmd.Flags().String("report-output", report.DefaultOutput, "Report file path without extension, .html and .md are added")
+	reportCmd.Flags().Int("report-top", report.DefaultTop, "Number of largest repositories to list")
+
+	viper.BindPFlag("GHMLFS_WORK_DIR", reportCmd.Flags().Lookup("work-dir"))
+	viper.BindPFlag("GHMLFS_RESULTS", reportCmd.Flags().Lookup("results"))
+	viper.BindPFlag("GHMLFS_REPORT_OUTPUT", reportCmd.Flags().Lookup("report-output"))
+	viper.BindPFlag("GHMLFS_REPORT_TOP", reportCmd.Flags().Lookup("report-top"))
+}
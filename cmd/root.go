@@ -1,8 +1,10 @@
 package cmd
 
 import (
-	"fmt"
+	"os"
 
+	"github.com/mona-actions/gh-migrate-lfs/internal/metrics"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -11,6 +13,21 @@ var rootCmd = &cobra.Command{
 	Use:   "migrate-lfs",
 	Short: "gh cli extension to migrate LFS files between git repositories",
 	Long:  "gh cli extension to migrate LFS files between git repositories",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		common.ConfigureOutput(viper.GetBool("GHMLFS_CI") || os.Getenv("GITHUB_ACTIONS") == "true")
+		common.RegisterSecrets(os.Getenv("GH_TOKEN"), os.Getenv("GITHUB_TOKEN"), os.Getenv("GH_ENTERPRISE_TOKEN"))
+		if err := common.ConfigureLogLevel(viper.GetString("GHMLFS_LOG_LEVEL")); err != nil {
+			return err
+		}
+		if addr := viper.GetString("GHMLFS_METRICS_ADDR"); addr != "" {
+			url, err := metrics.Serve(addr)
+			if err != nil {
+				return err
+			}
+			common.Printf("📈 Serving metrics at %s\n", url)
+		}
+		return nil
+	},
 }
 
 func Execute() error {
@@ -26,6 +43,12 @@ func init() {
 	rootCmd.PersistentFlags().String("no-proxy", "", "No proxy list")
 	rootCmd.PersistentFlags().Int("retry-max", 3, "Maximum retry attempts")
 	rootCmd.PersistentFlags().String("retry-delay", "1s", "Delay between retries")
+	rootCmd.PersistentFlags().Bool("resume", false, "Skip repositories that already completed this command, according to the migration state in the work dir")
+	rootCmd.PersistentFlags().Bool("only-failed", false, "Only process repositories that failed or were interrupted in the last run of this command")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "Resolve inputs and targets and show the planned git and LFS operations without writing anything")
+	rootCmd.PersistentFlags().String("log-level", "info", "Console output: debug, info, warn or error. Full git output always goes to the repository logs in <work-dir>/logs")
+	rootCmd.PersistentFlags().String("metrics-addr", "", "Serve Prometheus metrics at /metrics on this address, e.g. :9090")
+	rootCmd.PersistentFlags().Bool("ci", false, "GitHub Actions output: log groups, annotations and a step summary, without spinners (default when GITHUB_ACTIONS is set)")
 
 	// Bind flags to viper
 	viper.BindPFlag("HTTP_PROXY", rootCmd.PersistentFlags().Lookup("http-proxy"))
@@ -33,11 +56,22 @@ func init() {
 	viper.BindPFlag("NO_PROXY", rootCmd.PersistentFlags().Lookup("no-proxy"))
 	viper.BindPFlag("RETRY_MAX", rootCmd.PersistentFlags().Lookup("retry-max"))
 	viper.BindPFlag("RETRY_DELAY", rootCmd.PersistentFlags().Lookup("retry-delay"))
+	viper.BindPFlag("GHMLFS_RESUME", rootCmd.PersistentFlags().Lookup("resume"))
+	viper.BindPFlag("GHMLFS_ONLY_FAILED", rootCmd.PersistentFlags().Lookup("only-failed"))
+	viper.BindPFlag("GHMLFS_DRY_RUN", rootCmd.PersistentFlags().Lookup("dry-run"))
+	viper.BindPFlag("GHMLFS_LOG_LEVEL", rootCmd.PersistentFlags().Lookup("log-level"))
+	viper.BindPFlag("GHMLFS_METRICS_ADDR", rootCmd.PersistentFlags().Lookup("metrics-addr"))
+	viper.BindPFlag("GHMLFS_CI", rootCmd.PersistentFlags().Lookup("ci"))
 
 	// Add subcommands
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(pullCmd)
 	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(transferCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(reportCmd)
 
 	// hide -h, --help from global/proxy flags
 	rootCmd.Flags().BoolP("help", "h", false, "")
@@ -53,7 +87,7 @@ func initConfig() {
 	// Read config file
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			fmt.Printf("Error reading config file: %v\n", err)
+			common.Printf("Error reading config file: %v\n", err)
 		}
 	}
 
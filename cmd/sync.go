@@ -1,8 +1,9 @@
 package cmd
 
 import (
-	"fmt"
+	"os"
 
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
 	"github.com/mona-actions/gh-migrate-lfs/pkg/sync"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -13,18 +14,35 @@ var syncCmd = &cobra.Command{
 	Short: "Sync LFS objects to migrated repositories",
 	Long:  "Sync LFS objects to migrated repositories",
 	Run: func(cmd *cobra.Command, args []string) {
+		// Direct syncs stream from the source server and need no clones
+		direct, _ := cmd.Flags().GetBool("direct")
+		direct = direct || viper.GetBool("GHMLFS_DIRECT")
+
 		GetFlagOrEnv(cmd, map[string]bool{
 			"GHMLFS_FILE":                true,
 			"GHMLFS_TARGET_HOSTNAME":     false,
-			"GHMLFS_TARGET_ORGANIZATION": true,
+			"GHMLFS_TARGET_ORGANIZATION": false,
+			"GHMLFS_MAPPING_FILE":        false,
 			"GHMLFS_TARGET_TOKEN":        true,
-			"GHMLFS_WORK_DIR":            true,
+			"GHMLFS_WORK_DIR":            !direct,
 			"GHMLFS_WORKERS":             false,
+			"GHMLFS_ALLOW_INCOMPLETE":    false,
+			"GHMLFS_SOURCE_HOSTNAME":     false,
+			"GHMLFS_SOURCE_TOKEN":        direct,
+			"GHMLFS_SPOOL_SIZE":          false,
 		})
 
-		ShowConnectionStatus("sync")
+		if direct {
+			ShowConnectionStatus("sync-direct")
+		} else {
+			ShowConnectionStatus("sync")
+		}
 		if err := sync.SyncFromCSV(); err != nil {
-			fmt.Printf("failed to sync repositories: %v\n", err)
+			common.Printf("failed to sync repositories: %v\n", err)
+			// Gaps found by --verify must fail the run
+			if viper.GetBool("GHMLFS_VERIFY") {
+				os.Exit(1)
+			}
 		}
 	},
 }
@@ -32,10 +50,20 @@ var syncCmd = &cobra.Command{
 func init() {
 	syncCmd.Flags().StringP("file", "f", "", "Exported LFS repos file path, csv format (required)")
 	syncCmd.Flags().StringP("target-hostname", "n", "", "GitHub Enterprise Server hostname URL (optional)")
-	syncCmd.Flags().StringP("target-organization", "o", "", "Organization (required)")
+	syncCmd.Flags().StringP("target-organization", "o", "", "Organization (required unless every repository is mapped)")
 	syncCmd.Flags().StringP("target-token", "t", "", "GitHub token with repo scope (required)")
-	syncCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required)")
+	syncCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required unless --direct)")
 	syncCmd.Flags().IntP("workers", "w", 1, "Number of concurrent GIT workers to use")
+	syncCmd.Flags().StringP("mapping-file", "m", "", "Repository mapping file, source_org/source_repo,target_org/target_repo per line (optional)")
+	syncCmd.Flags().Bool("lfs-only", false, "Push only LFS objects, leaving git refs on the target untouched")
+	syncCmd.Flags().String("ref-source", "local", "Refs whose LFS objects are pushed in --lfs-only mode: local or target")
+	syncCmd.Flags().Bool("verify", false, "Verify that every LFS object exists on the target after syncing each repository")
+	syncCmd.Flags().Bool("allow-incomplete", false, "Push repositories with corrupt or missing local LFS objects, leaving those objects out")
+	syncCmd.Flags().Bool("create-missing", false, "Create target repositories that don't exist, using the source visibility, description and default branch")
+	syncCmd.Flags().Bool("direct", false, "Stream LFS objects from the source to the target without a clone, leaving git refs on the target untouched")
+	syncCmd.Flags().String("source-hostname", "", "Source GitHub Enterprise Server hostname URL, for --direct (optional)")
+	syncCmd.Flags().String("source-token", "", "Source GitHub token with repo scope, for --direct")
+	syncCmd.Flags().String("spool-size", "1GiB", "Temporary disk space for buffering objects whose direct stream is retried")
 
 	viper.BindPFlag("GHMLFS_FILE", syncCmd.Flags().Lookup("file"))
 	viper.BindPFlag("GHMLFS_TARGET_HOSTNAME", syncCmd.Flags().Lookup("target-hostname"))
@@ -43,4 +71,14 @@ func init() {
 	viper.BindPFlag("GHMLFS_TARGET_TOKEN", syncCmd.Flags().Lookup("target-token"))
 	viper.BindPFlag("GHMLFS_WORK_DIR", syncCmd.Flags().Lookup("work-dir"))
 	viper.BindPFlag("GHMLFS_WORKERS", syncCmd.Flags().Lookup("workers"))
+	viper.BindPFlag("GHMLFS_MAPPING_FILE", syncCmd.Flags().Lookup("mapping-file"))
+	viper.BindPFlag("GHMLFS_ALLOW_INCOMPLETE", syncCmd.Flags().Lookup("allow-incomplete"))
+	viper.BindPFlag("GHMLFS_CREATE_MISSING", syncCmd.Flags().Lookup("create-missing"))
+	viper.BindPFlag("GHMLFS_VERIFY", syncCmd.Flags().Lookup("verify"))
+	viper.BindPFlag("GHMLFS_LFS_ONLY", syncCmd.Flags().Lookup("lfs-only"))
+	viper.BindPFlag("GHMLFS_REF_SOURCE", syncCmd.Flags().Lookup("ref-source"))
+	viper.BindPFlag("GHMLFS_DIRECT", syncCmd.Flags().Lookup("direct"))
+	viper.BindPFlag("GHMLFS_SOURCE_HOSTNAME", syncCmd.Flags().Lookup("source-hostname"))
+	viper.BindPFlag("GHMLFS_SOURCE_TOKEN", syncCmd.Flags().Lookup("source-token"))
+	viper.BindPFlag("GHMLFS_SPOOL_SIZE", syncCmd.Flags().Lookup("spool-size"))
 }
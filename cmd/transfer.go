@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/transfer"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var transferCmd = &cobra.Command{
+	Use:   "transfer",
+	Short: "Pull, sync, verify and delete each repository as a pipeline",
+	Long:  "Runs each repository through pull, sync and verify, then deletes its local copy, so only a bounded amount of LFS content is on disk at once",
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMLFS_FILE":                true,
+			"GHMLFS_SOURCE_HOSTNAME":     false,
+			"GHMLFS_SOURCE_TOKEN":        true,
+			"GHMLFS_TARGET_HOSTNAME":     false,
+			"GHMLFS_TARGET_ORGANIZATION": false,
+			"GHMLFS_TARGET_TOKEN":        true,
+			"GHMLFS_WORK_DIR":            true,
+			"GHMLFS_MAPPING_FILE":        false,
+			"GHMLFS_PULL_WORKERS":        false,
+			"GHMLFS_SYNC_WORKERS":        false,
+			"GHMLFS_VERIFY_WORKERS":      false,
+			"GHMLFS_DISK_BUDGET":         false,
+			"GHMLFS_ALLOW_INCOMPLETE":    false,
+			"GHMLFS_KEEP_FAILED":         false,
+		})
+
+		ShowConnectionStatus("transfer")
+		if err := transfer.TransferFromCSV(); err != nil {
+			common.Printf("failed to transfer repositories: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	transferCmd.Flags().StringP("file", "f", "", "Exported LFS repos file path, csv format (required)")
+	transferCmd.Flags().String("source-hostname", "", "Source GitHub Enterprise Server hostname URL (optional)")
+	transferCmd.Flags().String("source-token", "", "Source GitHub token with repo scope (required)")
+	transferCmd.Flags().String("target-hostname", "", "Target GitHub Enterprise Server hostname URL (optional)")
+	transferCmd.Flags().StringP("target-organization", "o", "", "Organization (required unless every repository is mapped)")
+	transferCmd.Flags().String("target-token", "", "Target GitHub token with repo scope (required)")
+	transferCmd.Flags().StringP("work-dir", "d", "", "Working directory for repositories in transit (required)")
+	transferCmd.Flags().StringP("mapping-file", "m", "", "Repository mapping file, source_org/source_repo,target_org/target_repo per line (optional)")
+	transferCmd.Flags().Int("pull-workers", 1, "Number of repositories pulled concurrently")
+	transferCmd.Flags().Int("sync-workers", 1, "Number of repositories synced concurrently")
+	transferCmd.Flags().Int("verify-workers", 1, "Number of repositories verified concurrently")
+	transferCmd.Flags().String("disk-budget", "", "Disk space repositories in transit may use, new pulls wait above it, e.g. 200GiB (optional)")
+	transferCmd.Flags().Bool("allow-incomplete", false, "Push repositories with LFS objects missing on the source, leaving those objects out")
+	transferCmd.Flags().Bool("keep-failed", false, "Keep the local copies of failed repositories for a rerun, counted against the disk budget")
+
+	viper.BindPFlag("GHMLFS_FILE", transferCmd.Flags().Lookup("file"))
+	viper.BindPFlag("GHMLFS_SOURCE_HOSTNAME", transferCmd.Flags().Lookup("source-hostname"))
+	viper.BindPFlag("GHMLFS_SOURCE_TOKEN", transferCmd.Flags().Lookup("source-token"))
+	viper.BindPFlag("GHMLFS_TARGET_HOSTNAME", transferCmd.Flags().Lookup("target-hostname"))
+	viper.BindPFlag("GHMLFS_TARGET_ORGANIZATION", transferCmd.Flags().Lookup("target-organization"))
+	viper.BindPFlag("GHMLFS_TARGET_TOKEN", transferCmd.Flags().Lookup("target-token"))
+	viper.BindPFlag("GHMLFS_WORK_DIR", transferCmd.Flags().Lookup("work-dir"))
+	viper.BindPFlag("GHMLFS_MAPPING_FILE", transferCmd.Flags().Lookup("mapping-file"))
+	viper.BindPFlag("GHMLFS_PULL_WORKERS", transferCmd.Flags().Lookup("pull-workers"))
+	viper.BindPFlag("GHMLFS_SYNC_WORKERS", transferCmd.Flags().Lookup("sync-workers"))
+	viper.BindPFlag("GHMLFS_VERIFY_WORKERS", transferCmd.Flags().Lookup("verify-workers"))
+	viper.BindPFlag("GHMLFS_DISK_BUDGET", transferCmd.Flags().Lookup("disk-budget"))
+	viper.BindPFlag("GHMLFS_ALLOW_INCOMPLETE", transferCmd.Flags().Lookup("allow-incomplete"))
+	viper.BindPFlag("GHMLFS_KEEP_FAILED", transferCmd.Flags().Lookup("keep-failed"))
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/verify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies that all LFS objects exist in the target repositories",
+	Long:  "Verifies that all LFS objects referenced by the cloned repositories exist in the target repositories",
+	Run: func(cmd *cobra.Command, args []string) {
+		GetFlagOrEnv(cmd, map[string]bool{
+			"GHMLFS_FILE":                true,
+			"GHMLFS_TARGET_HOSTNAME":     false,
+			"GHMLFS_TARGET_ORGANIZATION": false,
+			"GHMLFS_TARGET_TOKEN":        true,
+			"GHMLFS_WORK_DIR":            true,
+			"GHMLFS_WORKERS":             false,
+			"GHMLFS_MAPPING_FILE":        false,
+		})
+
+		ShowConnectionStatus("verify")
+		if err := verify.VerifyFromCSV(); err != nil {
+			common.Printf("failed to verify repositories: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	verifyCmd.Flags().StringP("file", "f", "", "Exported LFS repos file path, csv format (required)")
+	verifyCmd.Flags().StringP("target-hostname", "n", "", "GitHub Enterprise Server hostname URL (optional)")
+	verifyCmd.Flags().StringP("target-organization", "o", "", "Organization (required unless every repository is mapped)")
+	verifyCmd.Flags().StringP("target-token", "t", "", "GitHub token with repo scope (required)")
+	verifyCmd.Flags().StringP("work-dir", "d", "", "Working directory with cloned repositories (required)")
+	verifyCmd.Flags().IntP("workers", "w", 1, "Number of concurrent GIT workers to use")
+	verifyCmd.Flags().StringP("mapping-file", "m", "", "Repository mapping file, source_org/source_repo,target_org/target_repo per line (optional)")
+
+	viper.BindPFlag("GHMLFS_FILE", verifyCmd.Flags().Lookup("file"))
+	viper.BindPFlag("GHMLFS_TARGET_HOSTNAME", verifyCmd.Flags().Lookup("target-hostname"))
+	viper.BindPFlag("GHMLFS_TARGET_ORGANIZATION", verifyCmd.Flags().Lookup("target-organization"))
+	viper.BindPFlag("GHMLFS_TARGET_TOKEN", verifyCmd.Flags().Lookup("target-token"))
+	viper.BindPFlag("GHMLFS_WORK_DIR", verifyCmd.Flags().Lookup("work-dir"))
+	viper.BindPFlag("GHMLFS_WORKERS", verifyCmd.Flags().Lookup("workers"))
+	viper.BindPFlag("GHMLFS_MAPPING_FILE", verifyCmd.Flags().Lookup("mapping-file"))
+}
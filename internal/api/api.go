@@ -2,14 +2,17 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/go-github/v66/github"
+	"github.com/mona-actions/gh-migrate-lfs/internal/metrics"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
 )
@@ -61,7 +64,21 @@ func newGitHubClientWithProxy(token string, proxyConfig *ProxyConfig) (*github.C
 		&oauth2.Token{AccessToken: token},
 	)
 
-	transport := &http.Transport{
+	transport := NewHTTPTransport(proxyConfig)
+
+	tc := oauth2.NewClient(ctx, ts)
+	tc.Transport = &oauth2.Transport{
+		Base:   metrics.Transport(transport),
+		Source: ts,
+	}
+
+	return github.NewClient(tc), nil
+}
+
+// NewHTTPTransport returns a transport that routes requests through the
+// configured proxies, for clients that don't go through the GitHub API
+func NewHTTPTransport(proxyConfig *ProxyConfig) *http.Transport {
+	return &http.Transport{
 		Proxy: func(req *http.Request) (*url.URL, error) {
 			if proxyConfig != nil && proxyConfig.NoProxy != "" {
 				noProxyURLs := strings.Split(proxyConfig.NoProxy, ",")
@@ -84,14 +101,6 @@ func newGitHubClientWithProxy(token string, proxyConfig *ProxyConfig) (*github.C
 			return nil, nil
 		},
 	}
-
-	tc := oauth2.NewClient(ctx, ts)
-	tc.Transport = &oauth2.Transport{
-		Base:   transport,
-		Source: ts,
-	}
-
-	return github.NewClient(tc), nil
 }
 
 func GetProxyConfigFromEnv() *ProxyConfig {
@@ -102,6 +111,10 @@ func GetProxyConfigFromEnv() *ProxyConfig {
 	}
 }
 
+// maxRateLimitWaits bounds how often one operation waits for a rate limit
+// to reset. Those waits don't use up its retries.
+const maxRateLimitWaits = 5
+
 func retryOperation(operation func() error) error {
 	maxRetries := viper.GetInt("MAX_RETRIES")
 	if maxRetries <= 0 {
@@ -114,12 +127,21 @@ func retryOperation(operation func() error) error {
 	}
 
 	var apiErr error
+	rateLimitWaits := 0
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		apiErr = operation()
 		if apiErr == nil {
 			return nil
 		}
 
+		if wait, limited := rateLimitWait(apiErr, time.Now()); limited && rateLimitWaits < maxRateLimitWaits {
+			rateLimitWaits++
+			attempt--
+			fmt.Printf("Rate limited, retrying in %v: %v\n", wait.Round(time.Second), apiErr)
+			time.Sleep(wait)
+			continue
+		}
+
 		if attempt < maxRetries {
 			waitTime := retryDelay * time.Duration(1<<uint(attempt-1))
 			fmt.Printf("Attempt %d failed, retrying in %v: %v\n", attempt, waitTime, apiErr)
@@ -129,6 +151,55 @@ func retryOperation(operation func() error) error {
 	return apiErr
 }
 
+// rateLimitWait reports whether err is a rate limit response and how long
+// to wait before repeating the request, going by the Retry-After or
+// X-RateLimit-Reset headers and waiting a minute when neither is set
+func rateLimitWait(err error, now time.Time) (time.Duration, bool) {
+	var wait time.Duration
+
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	var respErr *github.ErrorResponse
+	switch {
+	case errors.As(err, &rateErr):
+		wait = rateErr.Rate.Reset.Sub(now)
+	case errors.As(err, &abuseErr) && abuseErr.RetryAfter != nil:
+		wait = *abuseErr.RetryAfter
+	case errors.As(err, &abuseErr):
+		wait = time.Minute
+	case errors.As(err, &respErr) && respErr.Response != nil && isRateLimited(respErr.Response):
+		wait = retryAfter(respErr.Response.Header, now)
+	default:
+		return 0, false
+	}
+
+	// The reset time is in whole seconds and clocks drift, never retry
+	// right away
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait, true
+}
+
+// isRateLimited reports whether a response the client didn't recognize as a
+// rate limit error still is one
+func isRateLimited(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusForbidden && resp.Header.Get("Retry-After") != ""
+}
+
+// retryAfter reads how long to wait from the Retry-After header, in seconds,
+// or the X-RateLimit-Reset header, in seconds since the epoch
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if seconds, err := strconv.ParseInt(header.Get("Retry-After"), 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		return time.Unix(reset, 0).Sub(now)
+	}
+	return time.Minute
+}
+
 func readContent(rc io.ReadCloser) (string, error) {
 	defer rc.Close()
 	content, err := io.ReadAll(rc)
@@ -243,7 +314,9 @@ func CheckGitAttributes(org, repo, token string, depth int, hostname ...string)
 	return hasLFS, foundPath, nil
 }
 
-func GetRepositories(org, token string, hostname ...string) ([]string, error) {
+// GetRepositories lists every repository in an organization, including the
+// clone URLs reported by the API so callers don't have to build them
+func GetRepositories(org, token string, hostname ...string) ([]*github.Repository, error) {
 	if org == "" {
 		return nil, fmt.Errorf("organization name is required")
 	}
@@ -253,7 +326,7 @@ func GetRepositories(org, token string, hostname ...string) ([]string, error) {
 		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
 	}
 
-	var allRepos []string
+	var allRepos []*github.Repository
 	opts := &github.RepositoryListByOrgOptions{
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
@@ -271,7 +344,7 @@ func GetRepositories(org, token string, hostname ...string) ([]string, error) {
 
 			for _, repo := range repos {
 				if repo != nil && repo.Name != nil {
-					allRepos = append(allRepos, *repo.Name)
+					allRepos = append(allRepos, repo)
 				}
 			}
 
@@ -289,3 +362,81 @@ func GetRepositories(org, token string, hostname ...string) ([]string, error) {
 
 	return allRepos, nil
 }
+
+// GetRepository returns a single repository. A repository that doesn't exist
+// is reported as nil without an error.
+func GetRepository(owner, repo, token string, hostname ...string) (*github.Repository, error) {
+	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var repository *github.Repository
+	err = retryOperation(func() error {
+		r, resp, apiErr := client.Repositories.Get(context.Background(), owner, repo)
+		if apiErr != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				repository = nil
+				return nil
+			}
+			return apiErr
+		}
+		repository = r
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository %s/%s: %w", owner, repo, err)
+	}
+
+	return repository, nil
+}
+
+// CreateRepository creates an empty repository in an organization
+func CreateRepository(owner, repo, visibility, description, token string, hostname ...string) (*github.Repository, error) {
+	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	if visibility == "" {
+		visibility = "private"
+	}
+
+	newRepo := &github.Repository{
+		Name:        github.String(repo),
+		Description: github.String(description),
+		Visibility:  github.String(visibility),
+		Private:     github.Bool(visibility != "public"),
+	}
+
+	// Not retried, a timed out create may still have succeeded
+	created, _, err := client.Repositories.Create(context.Background(), owner, newRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository %s/%s: %w", owner, repo, err)
+	}
+
+	return created, nil
+}
+
+// SetDefaultBranch changes the default branch of a repository. The branch
+// must already exist.
+func SetDefaultBranch(owner, repo, branch, token string, hostname ...string) error {
+	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	err = retryOperation(func() error {
+		_, _, apiErr := client.Repositories.Edit(context.Background(), owner, repo, &github.Repository{
+			DefaultBranch: github.String(branch),
+		})
+		return apiErr
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to set default branch of %s/%s to %s: %w", owner, repo, branch, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v66/github"
+)
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	response := func(status int, headers map[string]string) *http.Response {
+		header := make(http.Header)
+		for k, v := range headers {
+			header.Set(k, v)
+		}
+		return &http.Response{StatusCode: status, Header: header}
+	}
+	retryAfter := 30 * time.Second
+	reset := strconv.FormatInt(now.Add(40*time.Second).Unix(), 10)
+
+	tests := []struct {
+		name        string
+		err         error
+		wantWait    time.Duration
+		wantLimited bool
+	}{
+		{
+			name:        "primary limit",
+			err:         &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: now.Add(90 * time.Second)}}},
+			wantWait:    90 * time.Second,
+			wantLimited: true,
+		},
+		{
+			name:        "primary limit already reset",
+			err:         &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: now.Add(-time.Minute)}}},
+			wantWait:    time.Second,
+			wantLimited: true,
+		},
+		{
+			name:        "wrapped primary limit",
+			err:         fmt.Errorf("failed to list commits: %w", &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: now.Add(time.Minute)}}}),
+			wantWait:    time.Minute,
+			wantLimited: true,
+		},
+		{
+			name:        "secondary limit with Retry-After",
+			err:         &github.AbuseRateLimitError{RetryAfter: &retryAfter},
+			wantWait:    30 * time.Second,
+			wantLimited: true,
+		},
+		{
+			name:        "secondary limit without a hint",
+			err:         &github.AbuseRateLimitError{},
+			wantWait:    time.Minute,
+			wantLimited: true,
+		},
+		{
+			name:        "429 with Retry-After",
+			err:         &github.ErrorResponse{Response: response(http.StatusTooManyRequests, map[string]string{"Retry-After": "12"})},
+			wantWait:    12 * time.Second,
+			wantLimited: true,
+		},
+		{
+			name:        "429 with X-RateLimit-Reset",
+			err:         &github.ErrorResponse{Response: response(http.StatusTooManyRequests, map[string]string{"X-RateLimit-Reset": reset})},
+			wantWait:    40 * time.Second,
+			wantLimited: true,
+		},
+		{
+			name:        "403 with Retry-After",
+			err:         &github.ErrorResponse{Response: response(http.StatusForbidden, map[string]string{"Retry-After": "5"})},
+			wantWait:    5 * time.Second,
+			wantLimited: true,
+		},
+		{
+			name: "403 without rate limit headers",
+			err:  &github.ErrorResponse{Response: response(http.StatusForbidden, nil)},
+		},
+		{
+			name: "server error",
+			err:  &github.ErrorResponse{Response: response(http.StatusBadGateway, nil)},
+		},
+		{
+			name: "network error",
+			err:  errors.New("connection reset by peer"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, limited := rateLimitWait(tt.err, now)
+			if limited != tt.wantLimited || wait != tt.wantWait {
+				t.Errorf("rateLimitWait() = %v, %v, want %v, %v", wait, limited, tt.wantWait, tt.wantLimited)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// blobWorkers is the number of blobs fetched concurrently by GetBlobs
+const blobWorkers = 8
+
+// RefCommit is a branch or tag and the commit it points at
+type RefCommit struct {
+	Ref string
+	SHA string
+}
+
+// ListRefCommits returns the commits of every branch and tag of a
+// repository, with annotated tags resolved to the commit they point at
+func ListRefCommits(owner, repo, token string, hostname ...string) ([]RefCommit, error) {
+	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var refs []RefCommit
+	for _, namespace := range []string{"heads", "tags"} {
+		opts := &github.ReferenceListOptions{
+			Ref:         namespace,
+			ListOptions: github.ListOptions{PerPage: 100},
+		}
+
+		// Each page is retried on its own, so a retry doesn't add the
+		// earlier pages again
+		for {
+			var page []*github.Reference
+			var resp *github.Response
+			err = retryOperation(func() error {
+				var apiErr error
+				page, resp, apiErr = client.Git.ListMatchingRefs(context.Background(), owner, repo, opts)
+				return apiErr
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s of %s/%s: %w", namespace, owner, repo, err)
+			}
+
+			for _, ref := range page {
+				var sha string
+				err = retryOperation(func() error {
+					var peelErr error
+					sha, peelErr = peelTag(client, owner, repo, ref.GetObject())
+					return peelErr
+				})
+				if err != nil {
+					return nil, fmt.Errorf("failed to list %s of %s/%s: %w", namespace, owner, repo, err)
+				}
+				refs = append(refs, RefCommit{Ref: ref.GetRef(), SHA: sha})
+			}
+
+			if resp == nil || resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	}
+
+	return refs, nil
+}
+
+// CommitTree is a commit, its root tree and its parents
+type CommitTree struct {
+	SHA     string
+	Tree    string
+	Parents []string
+}
+
+// ListCommits pages through the commits reachable from sha, newest first,
+// and passes each page to visit. Paging stops once visit returns false, so
+// history that was already seen doesn't cost more requests.
+func ListCommits(owner, repo, sha, token string, visit func([]CommitTree) (bool, error), hostname ...string) error {
+	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
+	if err != nil {
+		return fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	opts := &github.CommitsListOptions{
+		SHA:         sha,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		var page []*github.RepositoryCommit
+		var resp *github.Response
+		err = retryOperation(func() error {
+			var apiErr error
+			page, resp, apiErr = client.Repositories.ListCommits(context.Background(), owner, repo, opts)
+			return apiErr
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list commits of %s in %s/%s: %w", sha, owner, repo, err)
+		}
+
+		commits := make([]CommitTree, 0, len(page))
+		for _, commit := range page {
+			c := CommitTree{SHA: commit.GetSHA(), Tree: commit.GetCommit().GetTree().GetSHA()}
+			for _, parent := range commit.Parents {
+				c.Parents = append(c.Parents, parent.GetSHA())
+			}
+			commits = append(commits, c)
+		}
+
+		more, err := visit(commits)
+		if err != nil {
+			return err
+		}
+		if !more || resp == nil || resp.NextPage == 0 {
+			return nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// peelTag follows annotated tags until it reaches the object they point at
+func peelTag(client *github.Client, owner, repo string, object *github.GitObject) (string, error) {
+	for object.GetType() == "tag" {
+		tag, _, err := client.Git.GetTag(context.Background(), owner, repo, object.GetSHA())
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve tag %s: %w", object.GetSHA(), err)
+		}
+		object = tag.GetObject()
+	}
+	return object.GetSHA(), nil
+}
+
+// GetTree returns every entry of a tree or of the tree of a commit,
+// including subdirectories. Trees too large for the API to return in one
+// recursive response are read one subdirectory at a time, leaving out the
+// subdirectories in visited. Every tree read is added to visited.
+func GetTree(owner, repo, sha, token string, visited map[string]bool, hostname ...string) ([]*github.TreeEntry, error) {
+	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	tree, err := getTree(client, owner, repo, sha, true)
+	if err != nil {
+		return nil, err
+	}
+	if !tree.GetTruncated() {
+		visited[sha] = true
+		for _, entry := range tree.Entries {
+			if entry.GetType() == "tree" {
+				visited[entry.GetSHA()] = true
+			}
+		}
+		return tree.Entries, nil
+	}
+
+	return getTreeByLevel(client, owner, repo, sha, "", visited)
+}
+
+// getTreeByLevel reads a tree without the recursive option, descending into
+// each subdirectory not in visited with its own request. Paths are prefixed
+// with prefix.
+func getTreeByLevel(client *github.Client, owner, repo, sha, prefix string, visited map[string]bool) ([]*github.TreeEntry, error) {
+	tree, err := getTree(client, owner, repo, sha, false)
+	if err != nil {
+		return nil, err
+	}
+	if tree.GetTruncated() {
+		return nil, fmt.Errorf("tree %s of %s/%s has too many entries for the API", sha, owner, repo)
+	}
+	visited[sha] = true
+
+	var entries []*github.TreeEntry
+	for _, entry := range tree.Entries {
+		path := prefix + entry.GetPath()
+		entry.Path = &path
+		entries = append(entries, entry)
+
+		if entry.GetType() == "tree" && !visited[entry.GetSHA()] {
+			subtree, err := getTreeByLevel(client, owner, repo, entry.GetSHA(), path+"/", visited)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, subtree...)
+		}
+	}
+	return entries, nil
+}
+
+func getTree(client *github.Client, owner, repo, sha string, recursive bool) (*github.Tree, error) {
+	var tree *github.Tree
+	err := retryOperation(func() error {
+		t, _, apiErr := client.Git.GetTree(context.Background(), owner, repo, sha, recursive)
+		if apiErr != nil {
+			return apiErr
+		}
+		tree = t
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree %s of %s/%s: %w", sha, owner, repo, err)
+	}
+	return tree, nil
+}
+
+// GetBlobs returns the contents of blobs by SHA, fetching several at once
+func GetBlobs(owner, repo string, shas []string, token string, hostname ...string) (map[string][]byte, error) {
+	client, err := newGitHubClientWithHostname(token, getHostname(hostname...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitHub client: %w", err)
+	}
+
+	var mu sync.Mutex
+	blobs := make(map[string][]byte, len(shas))
+	var failures []string
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < blobWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sha := range queue {
+				var content []byte
+				err := retryOperation(func() error {
+					c, _, apiErr := client.Git.GetBlobRaw(context.Background(), owner, repo, sha)
+					content = c
+					return apiErr
+				})
+
+				mu.Lock()
+				if err != nil {
+					failures = append(failures, fmt.Sprintf("%s: %v", sha, err))
+				} else {
+					blobs[sha] = content
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, sha := range shas {
+		queue <- sha
+	}
+	close(queue)
+	wg.Wait()
+
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("failed to get %d blobs of %s/%s: %s", len(failures), owner, repo, strings.Join(failures, "; "))
+	}
+
+	return blobs, nil
+}
@@ -0,0 +1,421 @@
+package lfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/api"
+	"github.com/mona-actions/gh-migrate-lfs/internal/metrics"
+)
+
+const mediaType = "application/vnd.git-lfs+json"
+
+// Batch API operations
+const (
+	OperationDownload = "download"
+	OperationUpload   = "upload"
+)
+
+// Actions are refreshed this long before they expire so a transfer doesn't
+// start with credentials that run out mid-request
+const expiryBuffer = 5 * time.Second
+
+// ObjectSpec identifies an object in a batch request
+type ObjectSpec struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+type batchRequest struct {
+	Operation string       `json:"operation"`
+	Transfers []string     `json:"transfers"`
+	Objects   []ObjectSpec `json:"objects"`
+	HashAlgo  string       `json:"hash_algo"`
+}
+
+// Action is a transfer action returned by the Batch API
+type Action struct {
+	Href      string            `json:"href"`
+	Header    map[string]string `json:"header,omitempty"`
+	ExpiresIn int               `json:"expires_in,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the action can no longer be used safely
+func (a *Action) Expired() bool {
+	if a.ExpiresAt.IsZero() {
+		return false
+	}
+	return time.Now().Add(expiryBuffer).After(a.ExpiresAt)
+}
+
+// ObjectError is a per-object error returned by the Batch API
+type ObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ObjectError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+// ObjectResponse is the server's answer for one object of a batch request
+type ObjectResponse struct {
+	OID           string             `json:"oid"`
+	Size          int64              `json:"size"`
+	Authenticated bool               `json:"authenticated,omitempty"`
+	Actions       map[string]*Action `json:"actions,omitempty"`
+	Error         *ObjectError       `json:"error,omitempty"`
+}
+
+// BatchResponse is the response to a batch request
+type BatchResponse struct {
+	Transfer string           `json:"transfer"`
+	Objects  []ObjectResponse `json:"objects"`
+}
+
+// HTTPError is returned for unexpected HTTP responses from the LFS server
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("LFS server returned HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("LFS server returned HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the request may succeed when repeated
+func (e *HTTPError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// IntegrityError is returned when the contents of an object don't match its
+// pointer. The same source hands out the same contents again, so it isn't
+// retried.
+type IntegrityError struct {
+	OID     string
+	Message string
+}
+
+func (e *IntegrityError) Error() string {
+	return e.Message
+}
+
+func integrityError(oid, format string, args ...any) error {
+	return &IntegrityError{OID: oid, Message: fmt.Sprintf(format, args...)}
+}
+
+// Client talks to the Git LFS Batch API of a single repository and moves
+// objects with the basic transfer adapter
+type Client struct {
+	Endpoint   string
+	Token      string
+	HTTPClient *http.Client
+
+	// BatchSize is the number of objects sent per batch request
+	BatchSize int
+	// Concurrency is the number of objects transferred in parallel
+	Concurrency int
+	// Retries is the number of attempts for retryable failures
+	Retries int
+	// RetryDelay is the initial delay between attempts, doubled each time
+	RetryDelay time.Duration
+
+	// OnRead, when set, is called as object contents are transferred with
+	// the number of bytes just read. Retried transfers are counted again.
+	// It may be called from several goroutines at once.
+	OnRead func(n int64)
+}
+
+// NewClient returns a client for an LFS endpoint using the configured proxy
+func NewClient(endpoint, token string) *Client {
+	return &Client{
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Token:    token,
+		HTTPClient: &http.Client{
+			Transport: metrics.Transport(api.NewHTTPTransport(api.GetProxyConfigFromEnv())),
+		},
+		BatchSize:   100,
+		Concurrency: 8,
+		Retries:     3,
+		RetryDelay:  time.Second,
+	}
+}
+
+// EndpointForRepo returns the LFS endpoint of a repository from its HTTPS
+// clone URL, following the git-lfs convention of <clone-url>.git/info/lfs
+func EndpointForRepo(cloneURL string) string {
+	endpoint := strings.TrimSuffix(cloneURL, "/")
+	if !strings.HasSuffix(endpoint, ".git") {
+		endpoint += ".git"
+	}
+	return endpoint + "/info/lfs"
+}
+
+// Batch sends a batch request. Objects beyond BatchSize are split across
+// several requests and the responses combined.
+func (c *Client) Batch(operation string, objects []ObjectSpec) (*BatchResponse, error) {
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	combined := &BatchResponse{Transfer: "basic"}
+	for start := 0; start < len(objects); start += batchSize {
+		end := start + batchSize
+		if end > len(objects) {
+			end = len(objects)
+		}
+
+		var response *BatchResponse
+		err := c.retry(func() error {
+			var err error
+			response, err = c.batch(operation, objects[start:end])
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		combined.Objects = append(combined.Objects, response.Objects...)
+	}
+
+	return combined, nil
+}
+
+func (c *Client) batch(operation string, objects []ObjectSpec) (*BatchResponse, error) {
+	body, err := json.Marshal(batchRequest{
+		Operation: operation,
+		Transfers: []string{"basic"},
+		Objects:   objects,
+		HashAlgo:  "sha256",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.Endpoint+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+	c.setAuth(req)
+
+	received := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readHTTPError(resp)
+	}
+
+	var response BatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid batch response: %w", err)
+	}
+
+	if response.Transfer != "" && response.Transfer != "basic" {
+		return nil, fmt.Errorf("LFS server selected unsupported transfer adapter %q", response.Transfer)
+	}
+
+	// expires_in is relative to when the response was received, convert it
+	// so expiry can be checked the same way for both fields
+	for i := range response.Objects {
+		for _, action := range response.Objects[i].Actions {
+			if action.ExpiresAt.IsZero() && action.ExpiresIn > 0 {
+				action.ExpiresAt = received.Add(time.Duration(action.ExpiresIn) * time.Second)
+			}
+		}
+	}
+
+	return &response, nil
+}
+
+// Download starts downloading an object using its download action
+func (c *Client) Download(obj *ObjectResponse) (io.ReadCloser, error) {
+	action := obj.Actions["download"]
+	if action == nil {
+		return nil, fmt.Errorf("no download action for object %s", obj.OID)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, action.Href, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.applyAction(req, action)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, readHTTPError(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// Upload sends an object's contents using its upload action. Objects without
+// an upload action are already on the server and nothing is sent.
+func (c *Client) Upload(obj *ObjectResponse, r io.Reader) error {
+	action := obj.Actions["upload"]
+	if action == nil {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPut, action.Href, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = obj.Size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	c.applyAction(req, action)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return readHTTPError(resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// Verify confirms an upload with the verify action, when the server asks for one
+func (c *Client) Verify(obj *ObjectResponse) error {
+	action := obj.Actions["verify"]
+	if action == nil {
+		return nil
+	}
+
+	body, err := json.Marshal(ObjectSpec{OID: obj.OID, Size: obj.Size})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, action.Href, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+	c.applyAction(req, action)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return readHTTPError(resp)
+	}
+
+	return nil
+}
+
+// refresh requests a new action for an object whose action has expired
+func (c *Client) refresh(operation string, obj *ObjectResponse) (*ObjectResponse, error) {
+	response, err := c.Batch(operation, []ObjectSpec{{OID: obj.OID, Size: obj.Size}})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Objects) != 1 {
+		return nil, fmt.Errorf("expected 1 object in batch response, got %d", len(response.Objects))
+	}
+	return &response.Objects[0], nil
+}
+
+// setAuth authenticates requests to the LFS endpoint itself
+func (c *Client) setAuth(req *http.Request) {
+	if c.Token != "" {
+		req.SetBasicAuth("x-access-token", c.Token)
+	}
+}
+
+// applyAction adds the action's headers to a transfer request. The token is
+// only added when the action doesn't carry its own authorization and points
+// at the same host as the endpoint, so credentials never leak to storage
+// hosts.
+func (c *Client) applyAction(req *http.Request, action *Action) {
+	for key, value := range action.Header {
+		req.Header.Set(key, value)
+	}
+	if req.Header.Get("Authorization") != "" {
+		return
+	}
+	if endpoint, err := url.Parse(c.Endpoint); err == nil && endpoint.Host == req.URL.Host {
+		c.setAuth(req)
+	}
+}
+
+// retry runs operation until it succeeds, fails with an error that isn't
+// retryable, or runs out of attempts
+func (c *Client) retry(operation func() error) error {
+	attempts := c.Retries
+	if attempts <= 0 {
+		attempts = 1
+	}
+	delay := c.RetryDelay
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = operation()
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		if attempt < attempts {
+			time.Sleep(delay * time.Duration(1<<uint(attempt-1)))
+		}
+	}
+	return err
+}
+
+// IsRetryable reports whether an error from the client is worth retrying.
+// Network errors and server side failures are, client errors and objects
+// that don't match their pointer aren't.
+func IsRetryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Retryable()
+	}
+	var integrityErr *IntegrityError
+	if errors.As(err, &integrityErr) {
+		return false
+	}
+	var objErr *ObjectError
+	return !errors.As(err, &objErr)
+}
+
+func readHTTPError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	var message struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &message) == nil && message.Message != "" {
+		return &HTTPError{StatusCode: resp.StatusCode, Message: message.Message}
+	}
+
+	return &HTTPError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
@@ -0,0 +1,650 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testToken = "secret-token"
+
+// newObject returns the pointer of content
+func newObject(content string) Pointer {
+	sum := sha256.Sum256([]byte(content))
+	return Pointer{OID: hex.EncodeToString(sum[:]), Size: int64(len(content))}
+}
+
+// fakeServer is an LFS server with the basic transfer adapter. Transfers go
+// to a separate storage server, so requests cross hosts the way they do with
+// GitHub's object storage.
+type fakeServer struct {
+	lfs     *httptest.Server
+	storage *httptest.Server
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	batches [][]ObjectSpec
+	// verified lists the objects confirmed through the verify action
+	verified []string
+	// lfsAuth and storageAuth are the Authorization headers received
+	lfsAuth     []string
+	storageAuth []string
+
+	// batchFailures is the number of batch requests to fail with
+	// batchStatus before answering
+	batchFailures int
+	batchStatus   int
+	// uploadFailures is the number of uploads to fail with a 503
+	uploadFailures int
+	// dropUploads accepts uploads without storing them
+	dropUploads bool
+	// expired makes the first answer for each object carry an action that
+	// has already expired, expiresIn sets expires_in on every action
+	expired   bool
+	expiresIn int
+	answered  map[string]bool
+	// storageActions points actions at the storage server, otherwise at the
+	// LFS server itself
+	storageActions bool
+	// downloads counts the downloads of each object
+	downloads map[string]int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	s := &fakeServer{
+		objects:        make(map[string][]byte),
+		answered:       make(map[string]bool),
+		downloads:      make(map[string]int),
+		storageActions: true,
+	}
+	s.lfs = httptest.NewServer(http.HandlerFunc(s.serveLFS))
+	s.storage = httptest.NewServer(http.HandlerFunc(s.serveStorage))
+	t.Cleanup(s.lfs.Close)
+	t.Cleanup(s.storage.Close)
+	return s
+}
+
+func (s *fakeServer) add(content string) Pointer {
+	p := newObject(content)
+	s.mu.Lock()
+	s.objects[p.OID] = []byte(content)
+	s.mu.Unlock()
+	return p
+}
+
+func (s *fakeServer) has(oid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.objects[oid]
+	return ok
+}
+
+func (s *fakeServer) client() *Client {
+	c := NewClient(s.lfs.URL+"/repo.git/info/lfs", testToken)
+	c.HTTPClient = http.DefaultClient
+	c.RetryDelay = time.Millisecond
+	return c
+}
+
+func (s *fakeServer) serveLFS(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.lfsAuth = append(s.lfsAuth, r.Header.Get("Authorization"))
+	s.mu.Unlock()
+
+	if r.URL.Path != "/repo.git/info/lfs/objects/batch" {
+		s.serveStorage(w, r)
+		return
+	}
+
+	var request batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batches = append(s.batches, request.Objects)
+	if s.batchFailures > 0 {
+		s.batchFailures--
+		w.WriteHeader(s.batchStatus)
+		fmt.Fprint(w, `{"message":"try again"}`)
+		return
+	}
+
+	base := s.lfs.URL
+	if s.storageActions {
+		base = s.storage.URL
+	}
+
+	response := BatchResponse{Transfer: "basic"}
+	for _, spec := range request.Objects {
+		obj := ObjectResponse{OID: spec.OID, Size: spec.Size}
+		_, stored := s.objects[spec.OID]
+
+		action := func(path string) *Action {
+			a := &Action{Href: base + path + spec.OID, ExpiresIn: s.expiresIn}
+			if s.expired && !s.answered[spec.OID] {
+				a.ExpiresAt = time.Now().Add(-time.Minute)
+			}
+			return a
+		}
+
+		switch {
+		case request.Operation == OperationDownload && stored:
+			obj.Actions = map[string]*Action{"download": action("/objects/")}
+		case request.Operation == OperationDownload:
+			obj.Error = &ObjectError{Code: http.StatusNotFound, Message: "Object does not exist"}
+		case !stored:
+			obj.Actions = map[string]*Action{
+				"upload": action("/objects/"),
+				"verify": action("/verify/"),
+			}
+		}
+		s.answered[spec.OID] = true
+		response.Objects = append(response.Objects, obj)
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *fakeServer) serveStorage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Host == strings.TrimPrefix(s.storage.URL, "http://") {
+		s.storageAuth = append(s.storageAuth, r.Header.Get("Authorization"))
+	}
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/objects/"):
+		oid := strings.TrimPrefix(r.URL.Path, "/objects/")
+		s.downloads[oid]++
+		content, ok := s.objects[oid]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/objects/"):
+		if s.uploadFailures > 0 {
+			s.uploadFailures--
+			io.Copy(io.Discard, r.Body)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		content, err := io.ReadAll(r.Body)
+		if err != nil || s.dropUploads {
+			return
+		}
+		s.objects[strings.TrimPrefix(r.URL.Path, "/objects/")] = content
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/verify/"):
+		var spec ObjectSpec
+		json.NewDecoder(r.Body).Decode(&spec)
+		if _, ok := s.objects[spec.OID]; !ok {
+			http.Error(w, `{"message":"object not found"}`, http.StatusNotFound)
+			return
+		}
+		s.verified = append(s.verified, spec.OID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func resultsByOID(results []TransferResult) map[string]TransferResult {
+	byOID := make(map[string]TransferResult, len(results))
+	for _, r := range results {
+		byOID[r.Pointer.OID] = r
+	}
+	return byOID
+}
+
+func TestBatchSplitsRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		objects   int
+		batchSize int
+		want      []int
+	}{
+		{"single request", 3, 100, []int{3}},
+		{"exact multiple", 4, 2, []int{2, 2}},
+		{"remainder", 5, 2, []int{2, 2, 1}},
+		{"default size", 101, 0, []int{100, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t)
+			var specs []ObjectSpec
+			for i := 0; i < tt.objects; i++ {
+				p := server.add(fmt.Sprintf("object %d", i))
+				specs = append(specs, ObjectSpec{OID: p.OID, Size: p.Size})
+			}
+
+			client := server.client()
+			client.BatchSize = tt.batchSize
+			response, err := client.Batch(OperationDownload, specs)
+			if err != nil {
+				t.Fatalf("Batch() error = %v", err)
+			}
+
+			if len(response.Objects) != tt.objects {
+				t.Errorf("Batch() returned %d objects, want %d", len(response.Objects), tt.objects)
+			}
+			for i, obj := range response.Objects {
+				if obj.OID != specs[i].OID {
+					t.Errorf("object %d is %s, want %s", i, obj.OID, specs[i].OID)
+				}
+			}
+
+			var sizes []int
+			for _, batch := range server.batches {
+				sizes = append(sizes, len(batch))
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.want) {
+				t.Errorf("batch request sizes = %v, want %v", sizes, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		status   int
+		retries  int
+		wantErr  bool
+		requests int
+	}{
+		{"server errors until success", 2, http.StatusServiceUnavailable, 3, false, 3},
+		{"rate limited", 1, http.StatusTooManyRequests, 3, false, 2},
+		{"out of attempts", 3, http.StatusBadGateway, 3, true, 3},
+		{"client error isn't retried", 1, http.StatusUnprocessableEntity, 3, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t)
+			server.batchFailures = tt.failures
+			server.batchStatus = tt.status
+			p := server.add("content")
+
+			client := server.client()
+			client.Retries = tt.retries
+			_, err := client.Batch(OperationDownload, []ObjectSpec{{OID: p.OID, Size: p.Size}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Batch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(server.batches) != tt.requests {
+				t.Errorf("sent %d batch requests, want %d", len(server.batches), tt.requests)
+			}
+
+			var httpErr *HTTPError
+			if tt.wantErr && (!errors.As(err, &httpErr) || httpErr.StatusCode != tt.status) {
+				t.Errorf("Batch() error = %v, want HTTP %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &HTTPError{StatusCode: http.StatusInternalServerError}, true},
+		{"bad gateway", &HTTPError{StatusCode: http.StatusBadGateway}, true},
+		{"rate limited", &HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"not found", &HTTPError{StatusCode: http.StatusNotFound}, false},
+		{"unauthorized", &HTTPError{StatusCode: http.StatusUnauthorized}, false},
+		{"wrapped server error", fmt.Errorf("upload failed: %w", &HTTPError{StatusCode: http.StatusServiceUnavailable}), true},
+		{"object error", &ObjectError{Code: http.StatusNotFound}, false},
+		{"wrapped object error", fmt.Errorf("batch: %w", &ObjectError{Code: http.StatusUnprocessableEntity}), false},
+		{"integrity error", integrityError(testOID, "received object %s with checksum %s", testOID, testOID), false},
+		{"wrapped integrity error", fmt.Errorf("upload: %w", &IntegrityError{OID: testOID}), false},
+		{"network error", errors.New("connection reset by peer"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadObjects(t *testing.T) {
+	server := newFakeServer(t)
+	fetched := server.add("fetched from the server")
+	present := server.add("already in the store")
+	missing := newObject("not on the server")
+
+	store := &LocalStore{Root: t.TempDir()}
+	if err := store.Write(present, strings.NewReader("already in the store")); err != nil {
+		t.Fatal(err)
+	}
+
+	client := server.client()
+	var read atomic.Int64
+	client.OnRead = func(n int64) { read.Add(n) }
+	var progressed atomic.Int32
+	results := resultsByOID(client.DownloadObjects(store, []Pointer{fetched, present, missing, fetched}, func(TransferResult) {
+		progressed.Add(1)
+	}))
+
+	want := map[string]string{
+		fetched.OID: StatusTransferred,
+		present.OID: StatusSkipped,
+		missing.OID: StatusMissing,
+	}
+	for oid, status := range want {
+		if results[oid].Status != status {
+			t.Errorf("object %s is %s (%v), want %s", oid[:8], results[oid].Status, results[oid].Err, status)
+		}
+	}
+	if len(results) != len(want) {
+		t.Errorf("got %d results, want %d", len(results), len(want))
+	}
+	if progressed.Load() != 3 {
+		t.Errorf("progress called %d times, want 3", progressed.Load())
+	}
+
+	if err := store.Verify(fetched); err != nil {
+		t.Errorf("downloaded object doesn't verify: %v", err)
+	}
+	if read.Load() != fetched.Size {
+		t.Errorf("OnRead counted %d bytes, want %d", read.Load(), fetched.Size)
+	}
+}
+
+func TestUploadObjects(t *testing.T) {
+	server := newFakeServer(t)
+	onServer := server.add("already on the server")
+	uploaded := newObject("uploaded")
+	notStored := newObject("not in the local store")
+
+	store := &LocalStore{Root: t.TempDir()}
+	for p, content := range map[Pointer]string{onServer: "already on the server", uploaded: "uploaded"} {
+		if err := store.Write(p, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := server.client()
+	var read atomic.Int64
+	client.OnRead = func(n int64) { read.Add(n) }
+	results := resultsByOID(client.UploadObjects(store, []Pointer{onServer, uploaded, notStored}, nil))
+
+	want := map[string]string{
+		onServer.OID:  StatusSkipped,
+		uploaded.OID:  StatusTransferred,
+		notStored.OID: StatusMissing,
+	}
+	for oid, status := range want {
+		if results[oid].Status != status {
+			t.Errorf("object %s is %s (%v), want %s", oid[:8], results[oid].Status, results[oid].Err, status)
+		}
+	}
+
+	if !server.has(uploaded.OID) {
+		t.Error("uploaded object isn't on the server")
+	}
+	if fmt.Sprint(server.verified) != fmt.Sprint([]string{uploaded.OID}) {
+		t.Errorf("verified %v, want only the uploaded object", server.verified)
+	}
+	if read.Load() != uploaded.Size {
+		t.Errorf("OnRead counted %d bytes, want %d", read.Load(), uploaded.Size)
+	}
+}
+
+func TestVerifyFailureFailsUpload(t *testing.T) {
+	server := newFakeServer(t)
+	p := newObject("lost after upload")
+
+	server.dropUploads = true
+
+	store := &LocalStore{Root: t.TempDir()}
+	if err := store.Write(p, strings.NewReader("lost after upload")); err != nil {
+		t.Fatal(err)
+	}
+
+	results := server.client().UploadObjects(store, []Pointer{p}, nil)
+	if len(results) != 1 || results[0].Status != StatusFailed {
+		t.Fatalf("UploadObjects() = %+v, want a failed object", results)
+	}
+	var httpErr *HTTPError
+	if !errors.As(results[0].Err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Errorf("error = %v, want the verify action's HTTP 404", results[0].Err)
+	}
+}
+
+func TestExpiredActionsAreRefreshed(t *testing.T) {
+	tests := []struct {
+		name      string
+		expired   bool
+		expiresIn int
+		batches   int
+	}{
+		{"no expiry", false, 0, 1},
+		{"expires_at in the past", true, 0, 2},
+		// expires_in within the expiry buffer counts as expired on arrival,
+		// and again after the refresh, which is only tried once
+		{"expires_in within buffer", false, 1, 2},
+		{"expires_in far out", false, 3600, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t)
+			server.expired = tt.expired
+			server.expiresIn = tt.expiresIn
+			p := server.add("content")
+
+			store := &LocalStore{Root: t.TempDir()}
+			results := server.client().DownloadObjects(store, []Pointer{p}, nil)
+			if len(results) != 1 || results[0].Status != StatusTransferred {
+				t.Fatalf("DownloadObjects() = %+v, want a transferred object", results)
+			}
+			if len(server.batches) != tt.batches {
+				t.Errorf("sent %d batch requests, want %d", len(server.batches), tt.batches)
+			}
+		})
+	}
+}
+
+func TestExpiresInIsConverted(t *testing.T) {
+	server := newFakeServer(t)
+	server.expiresIn = 60
+	p := server.add("content")
+
+	before := time.Now()
+	response, err := server.client().Batch(OperationDownload, []ObjectSpec{{OID: p.OID, Size: p.Size}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiresAt := response.Objects[0].Actions["download"].ExpiresAt
+	if expiresAt.Before(before.Add(60*time.Second)) || expiresAt.After(time.Now().Add(60*time.Second)) {
+		t.Errorf("expires_at = %v, want 60s after the request", expiresAt)
+	}
+}
+
+func TestTokenOnlySentToLFSHost(t *testing.T) {
+	tests := []struct {
+		name           string
+		storageActions bool
+	}{
+		{"actions on a storage host", true},
+		{"actions on the LFS host", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t)
+			server.storageActions = tt.storageActions
+			p := server.add("content")
+
+			store := &LocalStore{Root: t.TempDir()}
+			results := server.client().DownloadObjects(store, []Pointer{p}, nil)
+			if len(results) != 1 || results[0].Status != StatusTransferred {
+				t.Fatalf("DownloadObjects() = %+v, want a transferred object", results)
+			}
+
+			for _, auth := range server.lfsAuth {
+				if auth == "" {
+					t.Error("request to the LFS host without the token")
+				}
+			}
+			for _, auth := range server.storageAuth {
+				if auth != "" {
+					t.Errorf("token sent to the storage host: %q", auth)
+				}
+			}
+			if tt.storageActions && len(server.storageAuth) == 0 {
+				t.Error("no request reached the storage host")
+			}
+		})
+	}
+}
+
+func TestApplyActionHeaders(t *testing.T) {
+	client := NewClient("https://github.example.com/org/repo.git/info/lfs", testToken)
+
+	tests := []struct {
+		name   string
+		href   string
+		header map[string]string
+		want   string
+	}{
+		{"same host gets the token", "https://github.example.com/org/repo.git/info/lfs/objects/1", nil, "Basic"},
+		{"other host doesn't", "https://objects.example.com/1", nil, ""},
+		{"action authorization wins", "https://github.example.com/objects/1", map[string]string{"Authorization": "RemoteAuth abc"}, "RemoteAuth abc"},
+		{"action authorization on other host", "https://objects.example.com/1", map[string]string{"Authorization": "RemoteAuth abc"}, "RemoteAuth abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.href, nil)
+			client.applyAction(req, &Action{Href: tt.href, Header: tt.header})
+
+			got := req.Header.Get("Authorization")
+			if !strings.HasPrefix(got, tt.want) || (tt.want == "" && got != "") {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyObjects(t *testing.T) {
+	source := newFakeServer(t)
+	target := newFakeServer(t)
+
+	streamed := source.add("streamed")
+	onTarget := source.add("already on the target")
+	target.add("already on the target")
+	missing := newObject("missing on the source")
+
+	client := target.client()
+	var read atomic.Int64
+	client.OnRead = func(n int64) { read.Add(n) }
+	results := resultsByOID(client.CopyObjects(source.client(), []Pointer{streamed, onTarget, missing}, NewSpool(t.TempDir(), 1024), nil))
+
+	want := map[string]string{
+		streamed.OID: StatusTransferred,
+		onTarget.OID: StatusSkipped,
+		missing.OID:  StatusMissing,
+	}
+	for oid, status := range want {
+		if results[oid].Status != status {
+			t.Errorf("object %s is %s (%v), want %s", oid[:8], results[oid].Status, results[oid].Err, status)
+		}
+	}
+	if !target.has(streamed.OID) {
+		t.Error("streamed object isn't on the target")
+	}
+	if read.Load() != streamed.Size {
+		t.Errorf("OnRead counted %d bytes, want %d", read.Load(), streamed.Size)
+	}
+}
+
+func TestCopyObjectsRetriesThroughSpool(t *testing.T) {
+	tests := []struct {
+		name       string
+		spoolLimit int64
+	}{
+		{"object fits the spool", 1024},
+		{"object larger than the spool", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeServer(t)
+			target := newFakeServer(t)
+			target.uploadFailures = 1
+			p := source.add("flaky upload")
+
+			dir := t.TempDir()
+			results := target.client().CopyObjects(source.client(), []Pointer{p}, NewSpool(dir, tt.spoolLimit), nil)
+			if len(results) != 1 || results[0].Status != StatusTransferred {
+				t.Fatalf("CopyObjects() = %+v, want a transferred object", results)
+			}
+			if !target.has(p.OID) {
+				t.Error("object isn't on the target")
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("spool left %d files behind", len(entries))
+			}
+		})
+	}
+}
+
+func TestCopyObjectsRejectsCorruptSource(t *testing.T) {
+	source := newFakeServer(t)
+	target := newFakeServer(t)
+	p := newObject("expected content")
+	source.mu.Lock()
+	source.objects[p.OID] = []byte("corrupt content!")
+	source.mu.Unlock()
+
+	results := target.client().CopyObjects(source.client(), []Pointer{p}, nil, nil)
+	if len(results) != 1 || results[0].Status != StatusFailed {
+		t.Fatalf("CopyObjects() = %+v, want a failed object", results)
+	}
+	if target.has(p.OID) {
+		t.Error("corrupt object was stored on the target")
+	}
+	var integrityErr *IntegrityError
+	if !errors.As(results[0].Err, &integrityErr) {
+		t.Errorf("CopyObjects() error = %v, want an IntegrityError", results[0].Err)
+	}
+
+	// The source hands out the same contents every time, so it isn't
+	// downloaded again
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	if got := source.downloads[p.OID]; got != 1 {
+		t.Errorf("corrupt object downloaded %d times, want 1", got)
+	}
+}
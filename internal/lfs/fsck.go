@@ -0,0 +1,55 @@
+package lfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// FsckResult is the outcome of checking local objects against their pointers
+type FsckResult struct {
+	Checked int
+	Corrupt []Pointer
+	Missing []Pointer
+}
+
+// OK reports whether every object is present and intact
+func (r FsckResult) OK() bool {
+	return len(r.Corrupt) == 0 && len(r.Missing) == 0
+}
+
+// Bad returns the object IDs of corrupt and missing objects
+func (r FsckResult) Bad() map[string]bool {
+	bad := make(map[string]bool, len(r.Corrupt)+len(r.Missing))
+	for _, p := range r.Corrupt {
+		bad[p.OID] = true
+	}
+	for _, p := range r.Missing {
+		bad[p.OID] = true
+	}
+	return bad
+}
+
+// Fsck hashes every object referenced by pointers and compares it with its
+// object ID and size, like git lfs fsck. With removeCorrupt set, corrupt
+// objects are deleted so the next download fetches them again instead of
+// trusting the bad copy.
+func Fsck(store *LocalStore, pointers []Pointer, removeCorrupt bool) FsckResult {
+	var result FsckResult
+	for _, p := range UniquePointers(pointers) {
+		result.Checked++
+
+		err := store.Verify(p)
+		switch {
+		case err == nil:
+		case errors.Is(err, fs.ErrNotExist):
+			result.Missing = append(result.Missing, p)
+		default:
+			result.Corrupt = append(result.Corrupt, p)
+			if removeCorrupt {
+				os.Remove(store.ObjectPath(p.OID))
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxPointerSize is the largest blob git-lfs treats as a pointer file
+const MaxPointerSize = 1024
+
+const pointerVersion = "https://git-lfs.github.com/spec/v1"
+
+var oidPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Pointer is an LFS pointer found in a repository
+type Pointer struct {
+	OID  string
+	Size int64
+	Path string // path of the pointer file in the tree, if known
+	Ref  string // first ref the pointer was found in, if known
+}
+
+// ParsePointer parses the contents of a pointer file. It reports false for
+// blobs that aren't valid pointers.
+func ParsePointer(data []byte) (Pointer, bool) {
+	if len(data) > MaxPointerSize || !bytes.HasPrefix(data, []byte("version ")) {
+		return Pointer{}, false
+	}
+
+	var p Pointer
+	var version string
+	hasSize := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "version":
+			version = value
+		case "oid":
+			p.OID = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return Pointer{}, false
+			}
+			p.Size = size
+			hasSize = true
+		}
+	}
+
+	// Pre-release pointers used a different version URL, accept both
+	if version != pointerVersion && version != "https://hawser.github.com/spec/v1" {
+		return Pointer{}, false
+	}
+
+	if !oidPattern.MatchString(p.OID) || !hasSize {
+		return Pointer{}, false
+	}
+
+	return p, true
+}
+
+// IsValidOID reports whether oid is a sha256 object ID
+func IsValidOID(oid string) bool {
+	return oidPattern.MatchString(oid)
+}
+
+// UniquePointers drops pointers with duplicate object IDs, keeping the first
+func UniquePointers(pointers []Pointer) []Pointer {
+	seen := make(map[string]bool)
+	var unique []Pointer
+	for _, p := range pointers {
+		if seen[p.OID] {
+			continue
+		}
+		seen[p.OID] = true
+		unique = append(unique, p)
+	}
+	return unique
+}
@@ -0,0 +1,105 @@
+package lfs
+
+import (
+	"strings"
+	"testing"
+)
+
+const testOID = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		want   Pointer
+		wantOK bool
+	}{
+		{
+			name:   "valid pointer",
+			data:   "version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\nsize 12345\n",
+			want:   Pointer{OID: testOID, Size: 12345},
+			wantOK: true,
+		},
+		{
+			name:   "pre-release version",
+			data:   "version https://hawser.github.com/spec/v1\noid sha256:" + testOID + "\nsize 1\n",
+			want:   Pointer{OID: testOID, Size: 1},
+			wantOK: true,
+		},
+		{
+			name:   "extension lines are ignored",
+			data:   "version https://git-lfs.github.com/spec/v1\next-0-foo sha256:" + testOID + "\noid sha256:" + testOID + "\nsize 0\n",
+			want:   Pointer{OID: testOID, Size: 0},
+			wantOK: true,
+		},
+		{
+			name:   "no trailing newline",
+			data:   "version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\nsize 7",
+			want:   Pointer{OID: testOID, Size: 7},
+			wantOK: true,
+		},
+		{
+			name: "unknown version",
+			data: "version https://example.com/spec/v2\noid sha256:" + testOID + "\nsize 1\n",
+		},
+		{
+			name: "missing size",
+			data: "version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\n",
+		},
+		{
+			name: "negative size",
+			data: "version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\nsize -1\n",
+		},
+		{
+			name: "invalid size",
+			data: "version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\nsize big\n",
+		},
+		{
+			name: "short oid",
+			data: "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a2146\nsize 1\n",
+		},
+		{
+			name: "uppercase oid",
+			data: "version https://git-lfs.github.com/spec/v1\noid sha256:" + strings.ToUpper(testOID) + "\nsize 1\n",
+		},
+		{
+			name: "not starting with version",
+			data: "oid sha256:" + testOID + "\nversion https://git-lfs.github.com/spec/v1\nsize 1\n",
+		},
+		{
+			name: "regular file",
+			data: "package main\n\nfunc main() {}\n",
+		},
+		{
+			name: "larger than a pointer",
+			data: "version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\nsize 1\n" + strings.Repeat("x", MaxPointerSize),
+		},
+		{
+			name: "empty",
+			data: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParsePointer([]byte(tt.data))
+			if ok != tt.wantOK {
+				t.Fatalf("ParsePointer() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePointer() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniquePointers(t *testing.T) {
+	a := Pointer{OID: testOID, Size: 1, Path: "a.bin"}
+	b := Pointer{OID: strings.Repeat("0", 64), Size: 2, Path: "b.bin"}
+	duplicate := Pointer{OID: testOID, Size: 1, Path: "copy-of-a.bin"}
+
+	got := UniquePointers([]Pointer{a, b, duplicate})
+	if len(got) != 2 || got[0] != a || got[1] != b {
+		t.Errorf("UniquePointers() = %+v, want the first pointer of each object", got)
+	}
+}
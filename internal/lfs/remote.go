@@ -0,0 +1,93 @@
+package lfs
+
+import "github.com/mona-actions/gh-migrate-lfs/internal/api"
+
+// minPointerSize is the size of the smallest valid pointer file
+const minPointerSize = len("version https://hawser.github.com/spec/v1\noid sha256:\nsize 0\n") + 64
+
+// ScanRemotePointers finds the LFS pointers in the history of every branch
+// and tag of a repository through the REST API, without a clone, so it
+// covers the same objects as a clone-based sync. History shared between refs
+// is listed once, every distinct tree is read once and only blobs small
+// enough to be pointers are downloaded. Each object is attributed to the
+// first ref and path it was found in.
+func ScanRemotePointers(owner, repo, token, hostname string) ([]Pointer, error) {
+	refs, err := api.ListRefCommits(owner, repo, token, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	type location struct {
+		path string
+		ref  string
+	}
+	candidates := make(map[string]location)
+	var order []string
+	seenCommits := make(map[string]bool)
+	seenTrees := make(map[string]bool)
+
+	for _, ref := range refs {
+		if seenCommits[ref.SHA] {
+			continue
+		}
+
+		// Commits of this ref whose history is still to be listed. Once
+		// none are left, the rest was seen through an earlier ref.
+		pending := map[string]bool{ref.SHA: true}
+		err := api.ListCommits(owner, repo, ref.SHA, token, func(commits []api.CommitTree) (bool, error) {
+			for _, commit := range commits {
+				if seenCommits[commit.SHA] {
+					continue
+				}
+				seenCommits[commit.SHA] = true
+				delete(pending, commit.SHA)
+				for _, parent := range commit.Parents {
+					if !seenCommits[parent] {
+						pending[parent] = true
+					}
+				}
+				if seenTrees[commit.Tree] {
+					continue
+				}
+
+				entries, err := api.GetTree(owner, repo, commit.Tree, token, seenTrees, hostname)
+				if err != nil {
+					return false, err
+				}
+
+				for _, entry := range entries {
+					size := entry.GetSize()
+					if entry.GetType() != "blob" || entry.GetMode() == "120000" || size < minPointerSize || size > MaxPointerSize {
+						continue
+					}
+					if _, ok := candidates[entry.GetSHA()]; ok {
+						continue
+					}
+					candidates[entry.GetSHA()] = location{path: entry.GetPath(), ref: ref.Ref}
+					order = append(order, entry.GetSHA())
+				}
+			}
+			return len(pending) > 0, nil
+		}, hostname)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	blobs, err := api.GetBlobs(owner, repo, order, token, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	var pointers []Pointer
+	for _, sha := range order {
+		p, ok := ParsePointer(blobs[sha])
+		if !ok {
+			continue
+		}
+		p.Path = candidates[sha].path
+		p.Ref = candidates[sha].ref
+		pointers = append(pointers, p)
+	}
+	return pointers, nil
+}
@@ -0,0 +1,263 @@
+package lfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTreeEntry is an entry of a tree served by fakeREST
+type fakeTreeEntry struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+	Size int    `json:"size,omitempty"`
+}
+
+// fakeREST serves the parts of the GitHub REST API that ScanRemotePointers
+// reads, one commit per page so paging can be counted
+type fakeREST struct {
+	server *httptest.Server
+
+	refs    map[string]string
+	parents map[string][]string
+	trees   map[string][]fakeTreeEntry
+	// commitTrees maps each commit to its root tree
+	commitTrees map[string]string
+	blobs       map[string]string
+	// truncated trees are cut short when read recursively
+	truncated map[string]bool
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newFakeREST(t *testing.T) *fakeREST {
+	t.Helper()
+	f := &fakeREST{
+		refs:        make(map[string]string),
+		parents:     make(map[string][]string),
+		trees:       make(map[string][]fakeTreeEntry),
+		commitTrees: make(map[string]string),
+		blobs:       make(map[string]string),
+		truncated:   make(map[string]bool),
+		requests:    make(map[string]int),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// history returns the commits reachable from sha, newest first
+func (f *fakeREST) history(sha string) []string {
+	var commits []string
+	seen := make(map[string]bool)
+	queue := []string{sha}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		commits = append(commits, c)
+		queue = append(queue, f.parents[c]...)
+	}
+	return commits
+}
+
+func (f *fakeREST) count(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[key]
+}
+
+func (f *fakeREST) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v3/repos/mona/api/")
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case path == "git/matching-refs/heads" || path == "git/matching-refs/tags":
+		prefix := "refs/" + strings.TrimPrefix(path, "git/matching-refs/") + "/"
+		var names []string
+		for name := range f.refs {
+			if strings.HasPrefix(name, prefix) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		refs := []map[string]any{}
+		for _, name := range names {
+			refs = append(refs, map[string]any{"ref": name, "object": map[string]string{"type": "commit", "sha": f.refs[name]}})
+		}
+		json.NewEncoder(w).Encode(refs)
+
+	case path == "commits":
+		sha := r.URL.Query().Get("sha")
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		f.mu.Lock()
+		f.requests["commits "+sha]++
+		f.mu.Unlock()
+
+		history := f.history(sha)
+		if page > len(history) {
+			json.NewEncoder(w).Encode([]any{})
+			return
+		}
+		if page < len(history) {
+			next := *r.URL
+			query := next.Query()
+			query.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = query.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, f.server.URL, next.RequestURI()))
+		}
+		c := history[page-1]
+		var parents []map[string]string
+		for _, p := range f.parents[c] {
+			parents = append(parents, map[string]string{"sha": p})
+		}
+		json.NewEncoder(w).Encode([]map[string]any{{
+			"sha":     c,
+			"commit":  map[string]any{"tree": map[string]string{"sha": f.commitTrees[c]}},
+			"parents": parents,
+		}})
+
+	case strings.HasPrefix(path, "git/trees/"):
+		sha := strings.TrimPrefix(path, "git/trees/")
+		recursive := r.URL.Query().Get("recursive") != ""
+		f.mu.Lock()
+		f.requests["tree "+sha]++
+		f.mu.Unlock()
+
+		entries, ok := f.trees[sha]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		truncated := recursive && f.truncated[sha]
+		if recursive && !truncated {
+			entries = f.flatten(sha, "")
+		}
+		json.NewEncoder(w).Encode(map[string]any{"sha": sha, "tree": entries, "truncated": truncated})
+
+	case strings.HasPrefix(path, "git/blobs/"):
+		sha := strings.TrimPrefix(path, "git/blobs/")
+		f.mu.Lock()
+		f.requests["blob "+sha]++
+		f.mu.Unlock()
+		content, ok := f.blobs[sha]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprint(w, content)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// flatten lists a tree the way a recursive request returns it
+func (f *fakeREST) flatten(sha, prefix string) []fakeTreeEntry {
+	var entries []fakeTreeEntry
+	for _, entry := range f.trees[sha] {
+		entry.Path = prefix + entry.Path
+		entries = append(entries, entry)
+		if entry.Type == "tree" {
+			entries = append(entries, f.flatten(entry.SHA, entry.Path+"/")...)
+		}
+	}
+	return entries
+}
+
+func blobEntry(path, sha, content string) fakeTreeEntry {
+	return fakeTreeEntry{Path: path, Mode: "100644", Type: "blob", SHA: sha, Size: len(content)}
+}
+
+func TestScanRemotePointers(t *testing.T) {
+	f := newFakeREST(t)
+
+	pointers := map[string]Pointer{
+		"blob-a1": newObject("a version 1"),
+		"blob-a2": newObject("a version 2"),
+		"blob-a3": newObject("a version 3"),
+		"blob-x":  newObject("asset"),
+	}
+	for sha, p := range pointers {
+		f.blobs[sha] = pointerFile(p)
+	}
+	f.blobs["blob-readme"] = "hello"
+
+	f.trees["assets"] = []fakeTreeEntry{blobEntry("x.bin", "blob-x", f.blobs["blob-x"])}
+	dir := fakeTreeEntry{Path: "assets", Mode: "040000", Type: "tree", SHA: "assets"}
+	f.trees["tree-1"] = []fakeTreeEntry{blobEntry("a.bin", "blob-a1", f.blobs["blob-a1"]), dir}
+	f.trees["tree-2"] = []fakeTreeEntry{blobEntry("a.bin", "blob-a1", f.blobs["blob-a1"]), dir, blobEntry("README", "blob-readme", "hello")}
+	f.trees["tree-3"] = []fakeTreeEntry{blobEntry("a.bin", "blob-a2", f.blobs["blob-a2"]), dir}
+	f.trees["tree-4"] = []fakeTreeEntry{blobEntry("a.bin", "blob-a3", f.blobs["blob-a3"]), dir}
+	// Too large for one recursive response, read by level instead
+	f.truncated["tree-3"] = true
+
+	// main: c1 <- c2 <- c3, feature branches off c2, the tag points at c1
+	f.parents["c2"] = []string{"c1"}
+	f.parents["c3"] = []string{"c2"}
+	f.parents["c4"] = []string{"c2"}
+	for i := 1; i <= 4; i++ {
+		f.commitTrees[fmt.Sprintf("c%d", i)] = fmt.Sprintf("tree-%d", i)
+	}
+	f.refs["refs/heads/feature"] = "c4"
+	f.refs["refs/heads/main"] = "c3"
+	f.refs["refs/tags/v1"] = "c1"
+
+	got, err := ScanRemotePointers("mona", "api", testToken, f.server.URL)
+	if err != nil {
+		t.Fatalf("ScanRemotePointers() error = %v", err)
+	}
+
+	byOID := make(map[string]Pointer)
+	for _, p := range got {
+		byOID[p.OID] = p
+	}
+	if len(got) != len(pointers) || len(byOID) != len(pointers) {
+		t.Fatalf("ScanRemotePointers() = %+v, want %d distinct pointers", got, len(pointers))
+	}
+	for sha, p := range pointers {
+		if _, ok := byOID[p.OID]; !ok {
+			t.Errorf("pointer of %s not found", sha)
+		}
+	}
+	if p := byOID[pointers["blob-x"].OID]; p.Path != "assets/x.bin" || p.Ref != "refs/heads/feature" {
+		t.Errorf("asset pointer found at %s in %s, want assets/x.bin in refs/heads/feature", p.Path, p.Ref)
+	}
+	if p := byOID[pointers["blob-a2"].OID]; p.Path != "a.bin" || p.Ref != "refs/heads/main" {
+		t.Errorf("pointer from the truncated tree found at %s in %s, want a.bin in refs/heads/main", p.Path, p.Ref)
+	}
+
+	// The feature branch lists its history first. main stops paging at c2,
+	// which was already seen, and the tag isn't listed at all.
+	for key, want := range map[string]int{
+		"commits c4":  3,
+		"commits c3":  1,
+		"commits c1":  0,
+		"tree tree-3": 2,
+		"tree assets": 0,
+		"blob blob-x": 1,
+	} {
+		if got := f.count(key); got != want {
+			t.Errorf("%d requests for %s, want %d", got, key, want)
+		}
+	}
+	if got := f.count("blob blob-readme"); got != 0 {
+		t.Errorf("%d requests for a blob too small to be a pointer", got)
+	}
+}
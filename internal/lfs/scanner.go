@@ -0,0 +1,243 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CommandLogger, when set, is called for each git command run by the
+// scanner once it exited, with the repository it ran in and its error
+// output. Pipelines are reported as separate commands.
+var CommandLogger func(repoPath string, args []string, elapsed time.Duration, output string, err error)
+
+func logCommand(cmd *exec.Cmd, started time.Time, output string, err error) {
+	if CommandLogger != nil {
+		CommandLogger(cmd.Dir, cmd.Args, time.Since(started), output, err)
+	}
+}
+
+// ListRefs returns the branches, tags and remote-tracking branches of a
+// local repository, which together cover everything a fresh clone holds
+func ListRefs(repoPath string) ([]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname)", "refs/heads", "refs/tags", "refs/remotes")
+	cmd.Dir = repoPath
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	started := time.Now()
+	output, err := cmd.Output()
+	logCommand(cmd, started, stderr.String(), err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs: %w", err)
+	}
+
+	var refs []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		// The symbolic origin/HEAD duplicates the default branch
+		if line == "" || strings.HasSuffix(line, "/HEAD") {
+			continue
+		}
+		refs = append(refs, line)
+	}
+	return refs, nil
+}
+
+// ScanPointers finds every LFS pointer reachable from refs in a local
+// repository, without needing git-lfs. Each object ID is reported once and
+// attributed to the first ref that reaches it. When refs is empty all refs
+// from ListRefs are scanned.
+func ScanPointers(repoPath string, refs []string) ([]Pointer, error) {
+	if len(refs) == 0 {
+		var err error
+		refs, err = ListRefs(repoPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var pointers []Pointer
+	seen := make(map[string]bool)
+	for i, ref := range refs {
+		// Only walk objects this ref adds over the refs before it
+		var input strings.Builder
+		input.WriteString(ref + "\n")
+		for _, previous := range refs[:i] {
+			input.WriteString("^" + previous + "\n")
+		}
+
+		candidates, err := pointerCandidates(repoPath, input.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", ref, err)
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		found, err := readPointers(repoPath, candidates)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pointers in %s: %w", ref, err)
+		}
+
+		for _, p := range found {
+			if seen[p.OID] {
+				continue
+			}
+			seen[p.OID] = true
+			p.Ref = ref
+			pointers = append(pointers, p)
+		}
+	}
+
+	return pointers, nil
+}
+
+// pointerCandidates lists blobs small enough to be pointers, keyed by blob
+// ID with their path. revisions is rev-list --stdin input.
+func pointerCandidates(repoPath, revisions string) (map[string]string, error) {
+	revList := exec.Command("git", "rev-list", "--objects", "--stdin")
+	revList.Dir = repoPath
+	revList.Stdin = strings.NewReader(revisions)
+	var revListStderr bytes.Buffer
+	revList.Stderr = &revListStderr
+
+	objects, err := revList.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	// %(rest) carries the path rev-list prints after each object ID
+	batchCheck := exec.Command("git", "cat-file", "--batch-check=%(objectname) %(objecttype) %(objectsize) %(rest)")
+	batchCheck.Dir = repoPath
+	batchCheck.Stdin = objects
+	var batchCheckStderr bytes.Buffer
+	batchCheck.Stderr = &batchCheckStderr
+	output, err := batchCheck.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	if err := revList.Start(); err != nil {
+		logCommand(revList, started, "", err)
+		return nil, err
+	}
+	if err := batchCheck.Start(); err != nil {
+		revList.Process.Kill()
+		revList.Wait()
+		logCommand(batchCheck, started, "", err)
+		return nil, err
+	}
+
+	candidates := make(map[string]string)
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 4)
+		if len(fields) < 3 || fields[1] != "blob" {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || size > MaxPointerSize {
+			continue
+		}
+		path := ""
+		if len(fields) == 4 {
+			path = fields[3]
+		}
+		candidates[fields[0]] = path
+	}
+	scanErr := scanner.Err()
+
+	batchErr := batchCheck.Wait()
+	revListWaitErr := revList.Wait()
+	logCommand(revList, started, revListStderr.String(), revListWaitErr)
+	logCommand(batchCheck, started, batchCheckStderr.String(), batchErr)
+	if revListWaitErr != nil {
+		return nil, fmt.Errorf("git rev-list failed: %s, %w", strings.TrimSpace(revListStderr.String()), revListWaitErr)
+	}
+	if batchErr != nil {
+		return nil, fmt.Errorf("git cat-file failed: %w", batchErr)
+	}
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	return candidates, nil
+}
+
+// readPointers reads candidate blobs and keeps those that parse as pointers
+func readPointers(repoPath string, candidates map[string]string) ([]Pointer, error) {
+	var input strings.Builder
+	for sha := range candidates {
+		input.WriteString(sha + "\n")
+	}
+
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = repoPath
+	cmd.Stdin = strings.NewReader(input.String())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		logCommand(cmd, started, "", err)
+		return nil, err
+	}
+	// wait reaps cat-file and logs it, on every return below
+	wait := func() error {
+		err := cmd.Wait()
+		logCommand(cmd, started, stderr.String(), err)
+		return err
+	}
+
+	var pointers []Pointer
+	reader := bufio.NewReader(stdout)
+	for {
+		header, err := reader.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			wait()
+			return nil, err
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			// "<sha> missing" for objects that vanished, nothing to read
+			continue
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			wait()
+			return nil, fmt.Errorf("unexpected cat-file header %q", header)
+		}
+
+		// Contents are followed by a newline
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(reader, content); err != nil {
+			wait()
+			return nil, err
+		}
+
+		if p, ok := ParsePointer(content[:size]); ok {
+			p.Path = candidates[fields[0]]
+			pointers = append(pointers, p)
+		}
+	}
+
+	if err := wait(); err != nil {
+		return nil, fmt.Errorf("git cat-file failed: %w", err)
+	}
+
+	return pointers, nil
+}
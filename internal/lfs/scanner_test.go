@@ -0,0 +1,169 @@
+package lfs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// testRepo is a local repository to scan
+type testRepo struct {
+	t    *testing.T
+	path string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	r := &testRepo{t: t, path: t.TempDir()}
+	r.git("init", "-q", "-b", "main")
+	return r
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = r.path
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+	return string(output)
+}
+
+// commit writes files and commits them
+func (r *testRepo) commit(files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.path, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.git("add", "-A")
+	r.git("commit", "-q", "-m", "commit")
+}
+
+func pointerFile(p Pointer) string {
+	return fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", p.OID, p.Size)
+}
+
+func TestScanPointers(t *testing.T) {
+	onMain := newObject("on main")
+	onBranch := newObject("on a branch")
+	inHistory := newObject("deleted later")
+	shared := newObject("on main and the branch")
+
+	repo := newTestRepo(t)
+	repo.commit(map[string]string{
+		"old.bin":    pointerFile(inHistory),
+		"README.md":  "not a pointer\n",
+		"shared.bin": pointerFile(shared),
+	})
+	repo.git("rm", "-q", "old.bin")
+	repo.commit(map[string]string{"assets/main.bin": pointerFile(onMain)})
+	repo.git("tag", "v1")
+	repo.git("checkout", "-q", "-b", "feature")
+	repo.commit(map[string]string{
+		"branch.bin":      pointerFile(onBranch),
+		"copy/shared.bin": pointerFile(shared),
+	})
+
+	tests := []struct {
+		name string
+		refs []string
+		want map[string]Pointer
+	}{
+		{
+			name: "all refs",
+			want: map[string]Pointer{
+				onBranch.OID:  {OID: onBranch.OID, Size: onBranch.Size, Path: "branch.bin", Ref: "refs/heads/feature"},
+				onMain.OID:    {OID: onMain.OID, Size: onMain.Size, Path: "assets/main.bin", Ref: "refs/heads/feature"},
+				inHistory.OID: {OID: inHistory.OID, Size: inHistory.Size, Path: "old.bin", Ref: "refs/heads/feature"},
+				shared.OID:    {OID: shared.OID, Size: shared.Size, Ref: "refs/heads/feature"},
+			},
+		},
+		{
+			name: "refs in order",
+			refs: []string{"refs/heads/main", "refs/heads/feature"},
+			want: map[string]Pointer{
+				onMain.OID:    {OID: onMain.OID, Size: onMain.Size, Path: "assets/main.bin", Ref: "refs/heads/main"},
+				inHistory.OID: {OID: inHistory.OID, Size: inHistory.Size, Path: "old.bin", Ref: "refs/heads/main"},
+				shared.OID:    {OID: shared.OID, Size: shared.Size, Path: "shared.bin", Ref: "refs/heads/main"},
+				onBranch.OID:  {OID: onBranch.OID, Size: onBranch.Size, Path: "branch.bin", Ref: "refs/heads/feature"},
+			},
+		},
+		{
+			name: "tag only",
+			refs: []string{"refs/tags/v1"},
+			want: map[string]Pointer{
+				onMain.OID:    {OID: onMain.OID, Size: onMain.Size, Path: "assets/main.bin", Ref: "refs/tags/v1"},
+				inHistory.OID: {OID: inHistory.OID, Size: inHistory.Size, Path: "old.bin", Ref: "refs/tags/v1"},
+				shared.OID:    {OID: shared.OID, Size: shared.Size, Path: "shared.bin", Ref: "refs/tags/v1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pointers, err := ScanPointers(repo.path, tt.refs)
+			if err != nil {
+				t.Fatalf("ScanPointers() error = %v", err)
+			}
+			if len(pointers) != len(tt.want) {
+				t.Errorf("ScanPointers() found %d pointers, want %d: %+v", len(pointers), len(tt.want), pointers)
+			}
+			for _, p := range pointers {
+				want, ok := tt.want[p.OID]
+				if !ok {
+					t.Errorf("unexpected pointer %+v", p)
+					continue
+				}
+				// The same blob sits at two paths, either may be reported
+				if p.OID == shared.OID && want.Path == "" {
+					want.Path = p.Path
+				}
+				if p != want {
+					t.Errorf("pointer = %+v, want %+v", p, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScanPointersUnknownRef(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit(map[string]string{"a.bin": pointerFile(newObject("a"))})
+
+	if _, err := ScanPointers(repo.path, []string{"refs/heads/missing"}); err == nil {
+		t.Error("ScanPointers() of an unknown ref succeeded")
+	}
+}
+
+func TestListRefs(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit(map[string]string{"a.txt": "a"})
+	repo.git("branch", "feature")
+	repo.git("tag", "v1")
+	repo.git("update-ref", "refs/remotes/origin/main", "HEAD")
+	repo.git("symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/main")
+
+	refs, err := ListRefs(repo.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(refs)
+
+	want := []string{"refs/heads/feature", "refs/heads/main", "refs/remotes/origin/main", "refs/tags/v1"}
+	if fmt.Sprint(refs) != fmt.Sprint(want) {
+		t.Errorf("ListRefs() = %v, want %v", refs, want)
+	}
+}
@@ -0,0 +1,126 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore is the LFS object directory of a local repository, laid out the
+// same way git-lfs stores objects: objects/<oid[0:2]>/<oid[2:4]>/<oid>
+type LocalStore struct {
+	Root string
+}
+
+// NewLocalStore returns the object store used by a repository, honoring
+// lfs.storage when it is configured
+func NewLocalStore(repoPath string) (*LocalStore, error) {
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate git directory of %s: %w", repoPath, err)
+	}
+	gitDir := strings.TrimSpace(string(output))
+
+	root := filepath.Join(gitDir, "lfs")
+
+	cmd = exec.Command("git", "config", "--get", "lfs.storage")
+	cmd.Dir = repoPath
+	if output, err := cmd.Output(); err == nil {
+		if storage := strings.TrimSpace(string(output)); storage != "" {
+			// Relative paths are relative to the git directory, as in git-lfs
+			if !filepath.IsAbs(storage) {
+				storage = filepath.Join(gitDir, storage)
+			}
+			root = storage
+		}
+	}
+
+	return &LocalStore{Root: root}, nil
+}
+
+// ObjectPath returns where an object is stored
+func (s *LocalStore) ObjectPath(oid string) string {
+	return filepath.Join(s.Root, "objects", oid[0:2], oid[2:4], oid)
+}
+
+// Has reports whether an object of the expected size is present. The
+// contents aren't hashed, see Verify for that.
+func (s *LocalStore) Has(p Pointer) bool {
+	info, err := os.Stat(s.ObjectPath(p.OID))
+	return err == nil && info.Size() == p.Size
+}
+
+// Open opens a stored object for reading
+func (s *LocalStore) Open(oid string) (*os.File, error) {
+	return os.Open(s.ObjectPath(oid))
+}
+
+// Verify hashes a stored object and checks it against its pointer
+func (s *LocalStore) Verify(p Pointer) error {
+	file, err := s.Open(p.OID)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+
+	if size != p.Size {
+		return integrityError(p.OID, "object %s is %d bytes, expected %d", p.OID, size, p.Size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != p.OID {
+		return integrityError(p.OID, "object %s has checksum %s", p.OID, sum)
+	}
+
+	return nil
+}
+
+// Write stores an object read from r. The contents are hashed while they are
+// written and only moved into place when they match the pointer, so a
+// failed or corrupted transfer never leaves a bad object behind.
+func (s *LocalStore) Write(p Pointer, r io.Reader) error {
+	path := s.ObjectPath(p.OID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmpDir := filepath.Join(s.Root, "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(tmpDir, p.OID+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if size != p.Size {
+		return integrityError(p.OID, "received %d bytes for object %s, expected %d", size, p.OID, p.Size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != p.OID {
+		return integrityError(p.OID, "received object %s with checksum %s", p.OID, sum)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,244 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Spool bounds the temporary disk space used to buffer objects whose direct
+// stream from source to target failed, so retries don't download them again
+// for every attempt
+type Spool struct {
+	Dir   string
+	Limit int64
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	used int64
+}
+
+// NewSpool returns a spool of limit bytes in dir, os.TempDir() when empty
+func NewSpool(dir string, limit int64) *Spool {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	s := &Spool{Dir: dir, Limit: limit}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// acquire reserves n bytes, waiting for space. It reports false for objects
+// larger than the whole spool, which are streamed again instead.
+func (s *Spool) acquire(n int64) bool {
+	if s == nil || n > s.Limit {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.used+n > s.Limit {
+		s.cond.Wait()
+	}
+	s.used += n
+	return true
+}
+
+func (s *Spool) release(n int64) {
+	s.mu.Lock()
+	s.used -= n
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// CopyObjects moves objects from the src server to this client's server
+// without a local store. Each object is streamed from the source download
+// action straight into the target upload action. When a stream fails with a
+// retryable error the object is buffered in the spool for the remaining
+// attempts. Objects the target already has are reported as skipped, objects
+// the source doesn't have with StatusMissing.
+func (c *Client) CopyObjects(src *Client, pointers []Pointer, spool *Spool, progress ProgressFunc) []TransferResult {
+	pointers = UniquePointers(pointers)
+	if len(pointers) == 0 {
+		return nil
+	}
+
+	specs := make([]ObjectSpec, len(pointers))
+	for i, p := range pointers {
+		specs[i] = ObjectSpec{OID: p.OID, Size: p.Size}
+	}
+
+	var results []TransferResult
+	response, err := src.Batch(OperationDownload, specs)
+	if err != nil {
+		for _, p := range pointers {
+			results = append(results, report(progress, TransferResult{Pointer: p, Status: StatusFailed, Err: fmt.Errorf("source batch request failed: %w", err)}))
+		}
+		return results
+	}
+
+	sources := make(map[string]ObjectResponse, len(response.Objects))
+	for _, obj := range response.Objects {
+		sources[obj.OID] = obj
+	}
+
+	var available []Pointer
+	for _, p := range pointers {
+		obj, ok := sources[p.OID]
+		switch {
+		case !ok:
+			results = append(results, report(progress, TransferResult{Pointer: p, Status: StatusFailed, Err: fmt.Errorf("object %s missing from source batch response", p.OID)}))
+		case obj.Error != nil && (obj.Error.Code == http.StatusNotFound || obj.Error.Code == http.StatusGone):
+			results = append(results, report(progress, TransferResult{Pointer: p, Status: StatusMissing, Err: obj.Error}))
+		case obj.Error != nil:
+			results = append(results, report(progress, TransferResult{Pointer: p, Status: StatusFailed, Err: obj.Error}))
+		case obj.Actions["download"] == nil:
+			results = append(results, report(progress, TransferResult{Pointer: p, Status: StatusFailed, Err: fmt.Errorf("no download action for object %s on the source", p.OID)}))
+		default:
+			available = append(available, p)
+		}
+	}
+
+	return append(results, c.transfer(OperationUpload, available, progress, func(p Pointer, obj *ObjectResponse) error {
+		source := sources[p.OID]
+		err := c.stream(src, &source, p, obj)
+		if err != nil && IsRetryable(err) {
+			err = c.copyAgain(src, &source, p, obj, spool)
+		}
+		if err != nil {
+			return err
+		}
+		return c.retry(func() error {
+			return c.Verify(obj)
+		})
+	})...)
+}
+
+// stream downloads an object from the source and uploads it in one pass.
+// The contents are checked while they pass through, a mismatch aborts the
+// upload before it completes.
+func (c *Client) stream(src *Client, source *ObjectResponse, p Pointer, obj *ObjectResponse) error {
+	if err := src.renew(OperationDownload, source); err != nil {
+		return err
+	}
+
+	body, err := src.Download(source)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return c.Upload(obj, newVerifyingReader(c.counting(body), p))
+}
+
+// copyAgain retries a failed stream. Objects that fit in the spool are
+// downloaded once and uploaded from disk, larger ones are streamed again.
+func (c *Client) copyAgain(src *Client, source *ObjectResponse, p Pointer, obj *ObjectResponse, spool *Spool) error {
+	if !spool.acquire(p.Size) {
+		return c.retry(func() error {
+			return c.stream(src, source, p, obj)
+		})
+	}
+	defer spool.release(p.Size)
+
+	var file *os.File
+	err := src.retry(func() error {
+		var err error
+		file, err = src.spoolObject(source, p, spool.Dir)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	return c.retry(func() error {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return c.Upload(obj, c.counting(file))
+	})
+}
+
+// spoolObject downloads an object into a temporary file in dir
+func (c *Client) spoolObject(source *ObjectResponse, p Pointer, dir string) (*os.File, error) {
+	if err := c.renew(OperationDownload, source); err != nil {
+		return nil, err
+	}
+
+	body, err := c.Download(source)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	file, err := os.CreateTemp(dir, "lfs-spool-"+p.OID+"-*")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(file, newVerifyingReader(body, p)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+// renew replaces an expired action of obj with a fresh one
+func (c *Client) renew(operation string, obj *ObjectResponse) error {
+	action := obj.Actions[operation]
+	if action == nil || !action.Expired() {
+		return nil
+	}
+
+	fresh, err := c.refresh(operation, obj)
+	if err != nil {
+		return fmt.Errorf("failed to refresh expired action: %w", err)
+	}
+	*obj = *fresh
+	return nil
+}
+
+// verifyingReader checks the size and checksum of an object as it's read.
+// Uploads send exactly the expected size and stop reading there, so the
+// check happens as soon as that much was read, and the read that completes
+// a mismatching object returns no bytes. That way the server never receives
+// the whole object.
+type verifyingReader struct {
+	r    io.Reader
+	p    Pointer
+	hash hash.Hash
+	size int64
+	// checked is set once the contents were compared with the pointer
+	checked bool
+}
+
+func newVerifyingReader(r io.Reader, p Pointer) *verifyingReader {
+	return &verifyingReader{r: r, p: p, hash: sha256.New()}
+}
+
+func (v *verifyingReader) Read(b []byte) (int, error) {
+	n, err := v.r.Read(b)
+	v.hash.Write(b[:n])
+	v.size += int64(n)
+
+	switch {
+	case v.size > v.p.Size:
+		return 0, integrityError(v.p.OID, "received more than %d bytes for object %s", v.p.Size, v.p.OID)
+	case v.size == v.p.Size && !v.checked:
+		v.checked = true
+		if sum := hex.EncodeToString(v.hash.Sum(nil)); sum != v.p.OID {
+			return 0, integrityError(v.p.OID, "received object %s with checksum %s", v.p.OID, sum)
+		}
+	case err == io.EOF && v.size != v.p.Size:
+		return n, integrityError(v.p.OID, "received %d bytes for object %s, expected %d", v.size, v.p.OID, v.p.Size)
+	}
+	return n, err
+}
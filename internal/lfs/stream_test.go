@@ -0,0 +1,105 @@
+package lfs
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpoolAcquire(t *testing.T) {
+	tests := []struct {
+		name  string
+		spool *Spool
+		size  int64
+		want  bool
+	}{
+		{"fits", NewSpool("", 100), 60, true},
+		{"exactly the limit", NewSpool("", 100), 100, true},
+		{"larger than the spool", NewSpool("", 100), 101, false},
+		{"no spool", nil, 1, false},
+		{"zero limit", NewSpool("", 0), 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.spool.acquire(tt.size); got != tt.want {
+				t.Errorf("acquire(%d) = %v, want %v", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpoolDefaultsToTempDir(t *testing.T) {
+	if dir := NewSpool("", 1).Dir; dir != os.TempDir() {
+		t.Errorf("NewSpool(\"\").Dir = %q, want %q", dir, os.TempDir())
+	}
+}
+
+func TestSpoolWaitsForSpace(t *testing.T) {
+	spool := NewSpool(t.TempDir(), 100)
+	if !spool.acquire(70) {
+		t.Fatal("first acquire failed")
+	}
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- spool.acquire(50)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire didn't wait for space")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	spool.release(70)
+	select {
+	case ok := <-acquired:
+		if !ok {
+			t.Error("acquire after release failed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire still waiting after release")
+	}
+}
+
+func TestVerifyingReader(t *testing.T) {
+	p := newObject("expected content")
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"matching", "expected content", false},
+		{"same size, other content", "unexpected stuff", true},
+		{"short", "expected", true},
+		{"long", "expected content and more", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(newVerifyingReader(strings.NewReader(tt.content), p))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("read error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// A mismatch never hands out the complete object
+			if tt.wantErr && int64(len(got)) >= p.Size {
+				t.Errorf("read %d bytes of a mismatching object", len(got))
+			}
+		})
+	}
+}
+
+func TestVerifyingReaderEmptyObject(t *testing.T) {
+	if _, err := io.ReadAll(newVerifyingReader(strings.NewReader(""), newObject(""))); err != nil {
+		t.Errorf("empty object failed verification: %v", err)
+	}
+
+	wrong := Pointer{OID: testOID, Size: 0}
+	if _, err := io.ReadAll(newVerifyingReader(strings.NewReader(""), wrong)); err == nil {
+		t.Error("empty content passed for a non-empty checksum")
+	}
+}
@@ -0,0 +1,242 @@
+package lfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Transfer outcomes for a single object
+const (
+	StatusTransferred = "transferred"
+	StatusSkipped     = "skipped" // already present at the destination
+	StatusMissing     = "missing" // the server doesn't have the object
+	StatusFailed      = "failed"
+)
+
+// maxSummarizedFailures limits how many object failures are included in an
+// error message
+const maxSummarizedFailures = 5
+
+// TransferResult is the outcome of transferring one object
+type TransferResult struct {
+	Pointer Pointer
+	Status  string
+	Err     error
+}
+
+// Failure describes a failed result for logs and error messages
+func (r TransferResult) Failure() string {
+	return fmt.Sprintf("%s (%s): %v", r.Pointer.OID, r.Pointer.Path, r.Err)
+}
+
+// SummarizeFailures joins the first failures for an error message, the
+// repository log has them all
+func SummarizeFailures(failures []string) string {
+	if len(failures) > maxSummarizedFailures {
+		return fmt.Sprintf("%s; and %d more", strings.Join(failures[:maxSummarizedFailures], "; "), len(failures)-maxSummarizedFailures)
+	}
+	return strings.Join(failures, "; ")
+}
+
+// ProgressFunc is called once per object as soon as its transfer finishes.
+// It may be called from several goroutines at once.
+type ProgressFunc func(TransferResult)
+
+// DownloadObjects downloads pointers that aren't in the store yet. Objects
+// the server reports as missing are returned with StatusMissing rather than
+// failing the whole transfer.
+func (c *Client) DownloadObjects(store *LocalStore, pointers []Pointer, progress ProgressFunc) []TransferResult {
+	var results []TransferResult
+	var pending []Pointer
+	for _, p := range UniquePointers(pointers) {
+		if store.Has(p) {
+			results = append(results, report(progress, TransferResult{Pointer: p, Status: StatusSkipped}))
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	return append(results, c.transfer(OperationDownload, pending, progress, func(p Pointer, obj *ObjectResponse) error {
+		return c.retry(func() error {
+			body, err := c.Download(obj)
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+			return store.Write(p, c.counting(body))
+		})
+	})...)
+}
+
+// UploadObjects uploads pointers from the store. Objects the server already
+// has come back without an upload action and are reported as skipped.
+// Objects missing from the store are reported with StatusMissing.
+func (c *Client) UploadObjects(store *LocalStore, pointers []Pointer, progress ProgressFunc) []TransferResult {
+	var results []TransferResult
+	var pending []Pointer
+	for _, p := range UniquePointers(pointers) {
+		if !store.Has(p) {
+			results = append(results, report(progress, TransferResult{
+				Pointer: p,
+				Status:  StatusMissing,
+				Err:     fmt.Errorf("object %s is not in the local store", p.OID),
+			}))
+			continue
+		}
+		pending = append(pending, p)
+	}
+
+	return append(results, c.transfer(OperationUpload, pending, progress, func(p Pointer, obj *ObjectResponse) error {
+		err := c.retry(func() error {
+			file, err := store.Open(p.OID)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return c.Upload(obj, c.counting(file))
+		})
+		if err != nil {
+			return err
+		}
+		return c.retry(func() error {
+			return c.Verify(obj)
+		})
+	})...)
+}
+
+// transfer runs a batch request for pointers and then calls move for each
+// object that has an action to perform, refreshing expired actions first
+func (c *Client) transfer(operation string, pointers []Pointer, progress ProgressFunc, move func(Pointer, *ObjectResponse) error) []TransferResult {
+	if len(pointers) == 0 {
+		return nil
+	}
+
+	specs := make([]ObjectSpec, len(pointers))
+	byOID := make(map[string]Pointer, len(pointers))
+	for i, p := range pointers {
+		specs[i] = ObjectSpec{OID: p.OID, Size: p.Size}
+		byOID[p.OID] = p
+	}
+
+	response, err := c.Batch(operation, specs)
+	if err != nil {
+		results := make([]TransferResult, len(pointers))
+		for i, p := range pointers {
+			results[i] = report(progress, TransferResult{Pointer: p, Status: StatusFailed, Err: fmt.Errorf("batch request failed: %w", err)})
+		}
+		return results
+	}
+
+	var mu sync.Mutex
+	var results []TransferResult
+	add := func(result TransferResult) {
+		mu.Lock()
+		results = append(results, report(progress, result))
+		mu.Unlock()
+	}
+
+	// Objects the server didn't mention at all failed without a reason
+	answered := make(map[string]bool)
+	for _, obj := range response.Objects {
+		answered[obj.OID] = true
+	}
+	for _, p := range pointers {
+		if !answered[p.OID] {
+			add(TransferResult{Pointer: p, Status: StatusFailed, Err: fmt.Errorf("object %s missing from batch response", p.OID)})
+		}
+	}
+
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	objects := make(chan ObjectResponse)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range objects {
+				p, ok := byOID[obj.OID]
+				if !ok {
+					continue
+				}
+				add(c.transferObject(operation, p, obj, false, move))
+			}
+		}()
+	}
+
+	for _, obj := range response.Objects {
+		objects <- obj
+	}
+	close(objects)
+	wg.Wait()
+
+	return results
+}
+
+func (c *Client) transferObject(operation string, p Pointer, obj ObjectResponse, refreshed bool, move func(Pointer, *ObjectResponse) error) TransferResult {
+	if obj.Error != nil {
+		if obj.Error.Code == http.StatusNotFound || obj.Error.Code == http.StatusGone {
+			return TransferResult{Pointer: p, Status: StatusMissing, Err: obj.Error}
+		}
+		return TransferResult{Pointer: p, Status: StatusFailed, Err: obj.Error}
+	}
+
+	action := obj.Actions[operation]
+	if action == nil {
+		// Uploads without an action are already stored on the server
+		if operation == OperationUpload {
+			return TransferResult{Pointer: p, Status: StatusSkipped}
+		}
+		return TransferResult{Pointer: p, Status: StatusFailed, Err: fmt.Errorf("no %s action for object %s", operation, p.OID)}
+	}
+
+	// Only refresh once, an action that is expired on arrival would loop
+	if action.Expired() && !refreshed {
+		fresh, err := c.refresh(operation, &obj)
+		if err != nil {
+			return TransferResult{Pointer: p, Status: StatusFailed, Err: fmt.Errorf("failed to refresh expired action: %w", err)}
+		}
+		return c.transferObject(operation, p, *fresh, true, move)
+	}
+
+	if err := move(p, &obj); err != nil {
+		return TransferResult{Pointer: p, Status: StatusFailed, Err: err}
+	}
+
+	return TransferResult{Pointer: p, Status: StatusTransferred}
+}
+
+// countingReader reports the bytes read through it to OnRead
+type countingReader struct {
+	r      io.Reader
+	onRead func(n int64)
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	if n > 0 {
+		c.onRead(int64(n))
+	}
+	return n, err
+}
+
+// counting wraps r to report what is read to OnRead, when it is set
+func (c *Client) counting(r io.Reader) io.Reader {
+	if c.OnRead == nil {
+		return r
+	}
+	return &countingReader{r: r, onRead: c.OnRead}
+}
+
+func report(progress ProgressFunc, result TransferResult) TransferResult {
+	if progress != nil {
+		progress(result)
+	}
+	return result
+}
@@ -0,0 +1,163 @@
+// Package metrics exposes the progress of a run as Prometheus metrics. The
+// metrics are always recorded and only served when an address is set.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ghmlfs"
+
+var (
+	repositories = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "repositories",
+		Help:      "Repositories processed, by phase and result status.",
+	}, []string{"phase", "status"})
+
+	lfsObjects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lfs_objects_total",
+		Help:      "LFS objects moved, by status: transferred, or skipped because the destination had them.",
+	}, []string{"status"})
+
+	lfsBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lfs_bytes_total",
+		Help:      "Size of the LFS objects moved, by status: transferred, or skipped because the destination had them.",
+	}, []string{"status"})
+
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "HTTP requests to GitHub and LFS servers, by host and status code.",
+	}, []string{"host", "code"})
+
+	rateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "api_rate_limit_remaining",
+		Help:      "Requests left in the current rate limit window, as last reported by each host.",
+	}, []string{"host"})
+
+	gitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "git_command_duration_seconds",
+		Help:      "Duration of git, git-lfs and gh commands, by command and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 4, 9),
+	}, []string{"command", "status"})
+
+	workers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers",
+		Help:      "Workers started for the current run.",
+	})
+
+	workersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers_busy",
+		Help:      "Workers processing a repository.",
+	})
+)
+
+// Serve serves the metrics at /metrics on addr in the background. The
+// address is claimed right away, so a port in use is reported before the run
+// starts.
+func Serve(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen for metrics on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Metrics server stopped: %v\n", err)
+		}
+	}()
+	return fmt.Sprintf("http://%s/metrics", listener.Addr()), nil
+}
+
+// RecordResult counts a repository under its result status. A result
+// replacing an earlier one for the same repository moves it from the old
+// status, given as previous, to the new one.
+func RecordResult(phase, previous, status string) {
+	if previous != "" {
+		repositories.WithLabelValues(phase, previous).Dec()
+	}
+	repositories.WithLabelValues(phase, status).Inc()
+}
+
+// RecordTransfer counts LFS objects and bytes transferred and skipped
+func RecordTransfer(objectsTransferred, bytesTransferred, objectsSkipped, bytesSkipped int64) {
+	lfsObjects.WithLabelValues("transferred").Add(float64(objectsTransferred))
+	lfsBytes.WithLabelValues("transferred").Add(float64(bytesTransferred))
+	lfsObjects.WithLabelValues("skipped").Add(float64(objectsSkipped))
+	lfsBytes.WithLabelValues("skipped").Add(float64(bytesSkipped))
+}
+
+// ObserveCommand records the duration of a command. Commands are named by
+// their program and subcommand, such as "git push", so arguments like URLs
+// don't end up in labels.
+func ObserveCommand(args []string, elapsed time.Duration, err error) {
+	if len(args) == 0 {
+		return
+	}
+	command := filepath.Base(args[0])
+	if len(args) > 1 && command != "sh" && !strings.HasPrefix(args[1], "-") {
+		command += " " + args[1]
+	}
+	status := "succeeded"
+	if err != nil {
+		status = "failed"
+	}
+	gitDuration.WithLabelValues(command, status).Observe(elapsed.Seconds())
+}
+
+// AddWorkers adds n started workers, or removes them when n is negative
+func AddWorkers(n int) {
+	workers.Add(float64(n))
+}
+
+// Busy marks a worker as processing a repository until the returned func is
+// called
+func Busy() func() {
+	workersBusy.Inc()
+	return workersBusy.Dec
+}
+
+// Transport counts the requests made through base and keeps track of the
+// rate limit each host reports
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		apiRequests.WithLabelValues(req.URL.Host, "error").Inc()
+		return resp, err
+	}
+
+	apiRequests.WithLabelValues(req.URL.Host, strconv.Itoa(resp.StatusCode)).Inc()
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		rateLimitRemaining.WithLabelValues(req.URL.Host).Set(float64(remaining))
+	}
+	return resp, nil
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// maxSummaryFailures limits the failures listed in the step summary
+const maxSummaryFailures = 50
+
+// ciMode is set when output goes to a GitHub Actions log
+var ciMode bool
+
+// ConfigureOutput switches to GitHub Actions output when ci is set: styling
+// and spinner animations are turned off, since the log keeps every frame
+func ConfigureOutput(ci bool) {
+	ciMode = ci
+	if ci {
+		pterm.DisableStyling()
+	}
+}
+
+// CIMode reports whether output goes to a GitHub Actions log
+func CIMode() bool {
+	return ciMode
+}
+
+// StartGroup starts a folded section of the Actions log
+func StartGroup(title string) {
+	if ciMode {
+		Printf("::group::%s\n", escapeWorkflowData(title))
+	}
+}
+
+// EndGroup ends the section started by StartGroup
+func EndGroup() {
+	if ciMode {
+		Println("::endgroup::")
+	}
+}
+
+// Annotate adds an error or warning annotation for a repository to the
+// workflow run
+func Annotate(level, repo, message string) {
+	if ciMode {
+		Printf("::%s title=%s::%s\n", level, escapeWorkflowProperty(repo), escapeWorkflowData(message))
+	}
+}
+
+// annotateResult annotates failed and partial results
+func annotateResult(result RepoResult) {
+	switch result.Status {
+	case ResultFailed:
+		Annotate("error", result.Record.Repository, fmt.Sprintf("%s failed (%s): %s", result.Phase, result.ErrorClass, result.Error))
+	case ResultPartial:
+		Annotate("warning", result.Record.Repository, fmt.Sprintf("%s incomplete: %s", result.Phase, result.Error))
+	}
+}
+
+// writeStepSummary appends the results of command as Markdown to the job's
+// step summary, when running in Actions
+func writeStepSummary(command string, results []RepoResult, elapsed time.Duration) error {
+	if !ciMode {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	var transferred TransferStats
+	var failures []RepoResult
+	for _, r := range results {
+		counts[r.Status]++
+		transferred.Add(r.Transfer)
+		if r.Status != ResultSucceeded {
+			failures = append(failures, r)
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Record.Repository < failures[j].Record.Repository
+	})
+
+	icon := "✅"
+	if counts[ResultFailed] > 0 {
+		icon = "❌"
+	} else if counts[ResultPartial] > 0 {
+		icon = "⚠️"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s gh migrate-lfs %s\n\n", icon, command)
+	fmt.Fprintf(&b, "| Succeeded | Partial | Failed | LFS objects transferred | Skipped, already present | Time |\n")
+	fmt.Fprintf(&b, "|---:|---:|---:|---:|---:|---:|\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d (%s) | %d (%s) | %v |\n\n",
+		counts[ResultSucceeded], counts[ResultPartial], counts[ResultFailed],
+		transferred.ObjectsTransferred, FormatBytes(transferred.BytesTransferred),
+		transferred.ObjectsSkipped, FormatBytes(transferred.BytesSkipped),
+		elapsed.Round(time.Second))
+
+	if len(failures) > 0 {
+		fmt.Fprintf(&b, "| Repository | Phase | Status | Cause | Error |\n|---|---|---|---|---|\n")
+		for i, r := range failures {
+			if i == maxSummaryFailures {
+				fmt.Fprintf(&b, "\n%d more, see the results report.\n", len(failures)-maxSummaryFailures)
+				break
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", r.Record.Repository, r.Phase, r.Status, r.ErrorClass,
+				strings.ReplaceAll(strings.Join(strings.Fields(r.Error), " "), "|", "\\|"))
+		}
+		b.WriteString("\n")
+	}
+
+	return AppendStepSummary(b.String())
+}
+
+// AppendStepSummary adds Markdown to the job's step summary, when running
+// in Actions
+func AppendStepSummary(markdown string) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if !ciMode || path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening step summary: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(Redact(markdown)); err != nil {
+		return fmt.Errorf("error writing step summary: %w", err)
+	}
+	return nil
+}
+
+// escapeWorkflowData escapes a workflow command message
+func escapeWorkflowData(value string) string {
+	value = strings.ReplaceAll(value, "%", "%25")
+	value = strings.ReplaceAll(value, "\r", "%0D")
+	return strings.ReplaceAll(value, "\n", "%0A")
+}
+
+// escapeWorkflowProperty escapes a workflow command property value
+func escapeWorkflowProperty(value string) string {
+	value = escapeWorkflowData(value)
+	value = strings.ReplaceAll(value, ":", "%3A")
+	return strings.ReplaceAll(value, ",", "%2C")
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrDiskSpaceUnsupported is returned on platforms where free space can't
+// be queried
+var ErrDiskSpaceUnsupported = errors.New("free disk space can't be determined on this platform")
+
+// FreeSpace returns the bytes available to the current user on the
+// filesystem holding path. Missing directories are resolved to their
+// closest existing parent, so a work dir that doesn't exist yet can be
+// checked before it's created.
+func FreeSpace(path string) (uint64, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return freeSpace(dir)
+}
+
+// ParseBytes parses a size such as "512MiB", "10GB" or "1048576". Decimal
+// and binary suffixes are both treated as powers of 1024.
+func ParseBytes(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	if s == "" {
+		return 0, fmt.Errorf("size is empty")
+	}
+
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	multiplier := int64(1)
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGTPE", s[n-1]); i >= 0 {
+			for ; i >= 0; i-- {
+				multiplier *= 1024
+			}
+			s = s[:n-1]
+		}
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q, use a number with an optional unit such as 500MiB or 20GB", value)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// DirSize sums the size of the files below dir
+func DirSize(dir string) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+package common
+
+func freeSpace(path string) (uint64, error) {
+	return 0, ErrDiskSpaceUnsupported
+}
@@ -0,0 +1,17 @@
+package common
+
+import "strings"
+
+// WebBaseURL converts a normalized API hostname (https://host/api/v3) into
+// the base URL used for git remotes. An empty hostname means GitHub.com.
+func WebBaseURL(hostname string) string {
+	if hostname == "" {
+		return "https://github.com"
+	}
+	hostname = strings.TrimSuffix(hostname, "/")
+	hostname = strings.TrimSuffix(hostname, "/api/v3")
+	if !strings.Contains(hostname, "://") {
+		hostname = "https://" + hostname
+	}
+	return hostname
+}
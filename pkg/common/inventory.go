@@ -0,0 +1,280 @@
+package common
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Inventory column names. Older exports only contain the first three.
+const (
+	ColumnRepository    = "Repository"
+	ColumnGitAttributes = "GitAttributesPaths"
+	ColumnCloneURL      = "CloneURL"
+	ColumnSSHURL        = "SSHURL"
+	ColumnSourceHost    = "SourceHost"
+	ColumnTargetRepo    = "TargetRepository"
+	ColumnVisibility    = "Visibility"
+	ColumnDefaultBranch = "DefaultBranch"
+	ColumnDescription   = "Description"
+	ColumnRepoSizeKB    = "RepositorySizeKB"
+	ColumnLFSSizeBytes  = "LFSSizeBytes"
+)
+
+// InventoryHeader is the header written by export
+var InventoryHeader = []string{
+	ColumnRepository,
+	ColumnGitAttributes,
+	ColumnCloneURL,
+	ColumnSSHURL,
+	ColumnSourceHost,
+	ColumnTargetRepo,
+	ColumnVisibility,
+	ColumnDefaultBranch,
+	ColumnDescription,
+	ColumnRepoSizeKB,
+	ColumnLFSSizeBytes,
+}
+
+// InventoryRecord is a single repository row of the exported LFS inventory
+type InventoryRecord struct {
+	Repository        string `json:"repository"`
+	GitAttributesPath string `json:"gitattributes_paths,omitempty"`
+	CloneURL          string `json:"clone_url,omitempty"`
+	SSHURL            string `json:"ssh_url,omitempty"`
+	SourceHost        string `json:"source_host,omitempty"`
+	TargetRepository  string `json:"target_repository,omitempty"`
+	Visibility        string `json:"visibility,omitempty"`
+	DefaultBranch     string `json:"default_branch,omitempty"`
+	Description       string `json:"description,omitempty"`
+	// RepositorySizeKB is the Git size reported by the API, without LFS
+	RepositorySizeKB int64 `json:"repository_size_kb,omitempty"`
+	// LFSSizeBytes is the size of the repository's LFS objects, 0 if unknown
+	LFSSizeBytes int64 `json:"lfs_size_bytes,omitempty"`
+}
+
+// Row returns the record as a CSV row matching InventoryHeader
+func (r InventoryRecord) Row() []string {
+	return []string{
+		r.Repository,
+		r.GitAttributesPath,
+		r.CloneURL,
+		r.SSHURL,
+		r.SourceHost,
+		r.TargetRepository,
+		r.Visibility,
+		r.DefaultBranch,
+		r.Description,
+		strconv.FormatInt(r.RepositorySizeKB, 10),
+		optionalInt(r.LFSSizeBytes),
+	}
+}
+
+func optionalInt(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+// EstimatedFootprint returns the disk space a clone of the repository with
+// its LFS objects is expected to take. The packed history and each LFS object
+// are stored once and checked out once, so both sizes count twice.
+func (r InventoryRecord) EstimatedFootprint() int64 {
+	return 2 * (r.RepositorySizeKB*1024 + r.EstimatedLFSSize())
+}
+
+// EstimatedLFSSize returns the LFS size of the repository. Inventories from
+// export don't have one, the Git size stands in for it then.
+func (r InventoryRecord) EstimatedLFSSize() int64 {
+	if r.LFSSizeBytes > 0 {
+		return r.LFSSizeBytes
+	}
+	return r.RepositorySizeKB * 1024
+}
+
+// SourceOwner returns the organization the repository was exported from,
+// taken from the clone URL path
+func (r InventoryRecord) SourceOwner() string {
+	parsed, err := url.Parse(r.CloneURL)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-2]
+}
+
+// ReadInventory reads an exported inventory CSV file. Columns are matched by
+// header name so files from older exports, or with extra columns, still load.
+// Duplicate repositories are dropped, keeping the first occurrence. A results
+// report of an earlier run loads only the repositories that failed.
+func ReadInventory(filename string) ([]InventoryRecord, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening input file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns[ColumnRepository]; !ok {
+		return nil, fmt.Errorf("CSV header is missing the %s column", ColumnRepository)
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	size := func(record []string, name string) (int64, error) {
+		value := field(record, name)
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid %s %q for %s", name, value, field(record, ColumnRepository))
+		}
+		return n, nil
+	}
+
+	_, isResults := columns[ColumnStatus]
+
+	var records []InventoryRecord
+	seen := make(map[string]bool)
+	var passed int
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("error reading CSV record: %w", err)
+		}
+
+		repoName := field(record, ColumnRepository)
+		if repoName == "" || seen[repoName] {
+			continue
+		}
+		seen[repoName] = true
+
+		if isResults && field(record, ColumnStatus) != ResultFailed {
+			passed++
+			continue
+		}
+
+		repoSize, err := size(record, ColumnRepoSizeKB)
+		if err != nil {
+			return nil, err
+		}
+		lfsSize, err := size(record, ColumnLFSSizeBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, InventoryRecord{
+			Repository:        repoName,
+			GitAttributesPath: field(record, ColumnGitAttributes),
+			CloneURL:          field(record, ColumnCloneURL),
+			SSHURL:            field(record, ColumnSSHURL),
+			SourceHost:        field(record, ColumnSourceHost),
+			TargetRepository:  field(record, ColumnTargetRepo),
+			Visibility:        field(record, ColumnVisibility),
+			DefaultBranch:     field(record, ColumnDefaultBranch),
+			Description:       field(record, ColumnDescription),
+			RepositorySizeKB:  repoSize,
+			LFSSizeBytes:      lfsSize,
+		})
+	}
+
+	if isResults {
+		Infof("🔁 %s is a results report: %d failed repositories selected, %d skipped\n", filename, len(records), passed)
+	}
+	return records, nil
+}
+
+// WriteInventory writes records to filename using InventoryHeader
+func WriteInventory(filename string, records []InventoryRecord) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	if err := writer.Write(InventoryHeader); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	for _, record := range records {
+		if err := writer.Write(record.Row()); err != nil {
+			return fmt.Errorf("error writing repository data: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ValidateCloneURL checks that a clone URL from the inventory can be used
+// with git over HTTPS. When sourceHost is set the URL must point at it.
+func ValidateCloneURL(cloneURL, sourceHost string) (*url.URL, error) {
+	if cloneURL == "" {
+		return nil, fmt.Errorf("clone URL is empty")
+	}
+
+	parsed, err := url.Parse(cloneURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clone URL %q: %w", cloneURL, err)
+	}
+
+	if parsed.Scheme != "https" && parsed.Scheme != "http" {
+		return nil, fmt.Errorf("clone URL %q must use http or https", cloneURL)
+	}
+
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("clone URL %q has no host", cloneURL)
+	}
+
+	if parsed.User != nil {
+		return nil, fmt.Errorf("clone URL for %s must not contain credentials", parsed.Host)
+	}
+
+	// Exports made before clone URLs came from the API used the API base
+	// URL for GHES, which git can't clone from
+	if strings.Contains(parsed.Path, "/api/v3/") {
+		return nil, fmt.Errorf("clone URL %q points at the REST API, re-run export to refresh the inventory", cloneURL)
+	}
+
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("clone URL %q is not in the form https://host/owner/repo.git", cloneURL)
+	}
+
+	if sourceHost != "" && !strings.EqualFold(parsed.Host, sourceHost) {
+		return nil, fmt.Errorf("clone URL %q does not match source host %s", cloneURL, sourceHost)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,243 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/internal/metrics"
+	"github.com/pterm/pterm"
+)
+
+// Console log levels for --log-level
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// LogsDir is the directory of the work dir holding the per-repository logs
+const LogsDir = "logs"
+
+var (
+	logLevel = LogLevelInfo
+
+	logsMu   sync.Mutex
+	logsDir  string
+	repoLogs = make(map[string]*RepoLog)
+)
+
+func init() {
+	// The LFS scanner can't use RepoLog, so it reports its commands here
+	lfs.CommandLogger = func(repoPath string, args []string, elapsed time.Duration, output string, err error) {
+		l := RepoLogger(filepath.Base(repoPath))
+		l.begin(args)
+		l.finish(args, elapsed, output, err)
+	}
+}
+
+// ConfigureLogLevel sets which messages reach the console. Debug adds the
+// commands run for each repository and their output, warn hides progress
+// and success messages, and error hides warnings too. The repository logs
+// always get everything.
+func ConfigureLogLevel(level string) error {
+	switch strings.ToLower(level) {
+	case "", LogLevelInfo:
+		logLevel = LogLevelInfo
+	case LogLevelDebug:
+		logLevel = LogLevelDebug
+		pterm.EnableDebugMessages()
+	case LogLevelWarn:
+		logLevel = LogLevelWarn
+		pterm.Info.Writer = io.Discard
+		pterm.Success.Writer = io.Discard
+	case LogLevelError:
+		logLevel = LogLevelError
+		pterm.Info.Writer = io.Discard
+		pterm.Success.Writer = io.Discard
+		pterm.Warning.Writer = io.Discard
+	default:
+		return fmt.Errorf("invalid log level %q, expected %s, %s, %s or %s", level, LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError)
+	}
+	return nil
+}
+
+// Infof prints a progress message to the console, unless the log level is
+// warn or error
+func Infof(format string, args ...any) {
+	if logLevel == LogLevelDebug || logLevel == LogLevelInfo {
+		Printf(format, args...)
+	}
+}
+
+// Warnf prints a warning to the console, unless the log level is error
+func Warnf(format string, args ...any) {
+	if logLevel != LogLevelError {
+		Printf(format, args...)
+	}
+}
+
+// ConfigureRepoLogs writes a log per repository to the logs directory of
+// workDir, with secrets redacted. An empty workDir turns the logs off.
+func ConfigureRepoLogs(workDir string) {
+	logsMu.Lock()
+	defer logsMu.Unlock()
+
+	logsDir = ""
+	if workDir != "" {
+		logsDir = filepath.Join(workDir, LogsDir)
+	}
+	repoLogs = make(map[string]*RepoLog)
+}
+
+// RepoLogsDir returns the directory of the repository logs, or an empty
+// string when they are off
+func RepoLogsDir() string {
+	logsMu.Lock()
+	defer logsMu.Unlock()
+	return logsDir
+}
+
+// GitTraceEnv returns the environment that makes git trace its commands and
+// HTTP requests, at the debug level when the trace lands in a repository log
+func GitTraceEnv() []string {
+	if logLevel != LogLevelDebug || RepoLogsDir() == "" {
+		return nil
+	}
+	return []string{"GIT_TRACE=1", "GIT_CURL_VERBOSE=1"}
+}
+
+// RepoLog is the log file of one repository. A nil log writes nothing, for
+// commands run without a work dir.
+type RepoLog struct {
+	repo string
+	path string
+	mu   sync.Mutex
+}
+
+// RepoLogger returns the log of a repository
+func RepoLogger(repo string) *RepoLog {
+	logsMu.Lock()
+	defer logsMu.Unlock()
+
+	if logsDir == "" {
+		return nil
+	}
+	if l, ok := repoLogs[repo]; ok {
+		return l
+	}
+	l := &RepoLog{repo: repo, path: filepath.Join(logsDir, repo+".log")}
+	repoLogs[repo] = l
+	return l
+}
+
+// Printf adds a timestamped line to the log
+func (l *RepoLog) Printf(format string, args ...any) {
+	if l == nil {
+		return
+	}
+	l.write(fmt.Sprintf("%s %s\n", time.Now().UTC().Format(time.RFC3339), Redact(fmt.Sprintf(format, args...))))
+}
+
+// Run runs cmd and returns its combined stdout and stderr, like
+// CombinedOutput. The command line and its output are logged.
+func (l *RepoLog) Run(cmd *exec.Cmd) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := l.run(cmd, func() string { return output.String() })
+	return output.Bytes(), err
+}
+
+// Output runs cmd and returns its stdout, like Output. The command line and
+// both output streams are logged.
+func (l *RepoLog) Output(cmd *exec.Cmd) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := l.run(cmd, func() string { return stdout.String() + stderr.String() })
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitErr.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+func (l *RepoLog) run(cmd *exec.Cmd, output func() string) error {
+	l.begin(cmd.Args)
+	started := time.Now()
+	err := cmd.Run()
+	l.finish(cmd.Args, time.Since(started), output(), err)
+	return err
+}
+
+// begin logs the command line of a command
+func (l *RepoLog) begin(args []string) {
+	command := strings.Join(args, " ")
+	l.Printf("$ %s", command)
+	pterm.Debug.Printf("%s: %s\n", l.name(), command)
+}
+
+// finish logs the output and outcome of a command
+func (l *RepoLog) finish(args []string, elapsed time.Duration, output string, err error) {
+	metrics.ObserveCommand(args, elapsed, err)
+
+	if out := Redact(output); out != "" {
+		if !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		l.write(out)
+		pterm.Debug.Print(out)
+	}
+	if err != nil {
+		l.Printf("%s failed after %v: %v", args[0], elapsed.Round(time.Millisecond), err)
+	} else {
+		l.Printf("%s done in %v", args[0], elapsed.Round(time.Millisecond))
+	}
+}
+
+func (l *RepoLog) name() string {
+	if l == nil {
+		return "git"
+	}
+	return l.repo
+}
+
+// write appends to the log file, which is opened for each write so no files
+// are left open between commands
+func (l *RepoLog) write(s string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.WriteString(s)
+}
+
+// logResult records the outcome of a phase in the repository log
+func logResult(result RepoResult) {
+	l := RepoLogger(result.Record.Repository)
+	if result.Error != "" {
+		l.Printf("%s %s (%s): %s", result.Phase, result.Status, result.ErrorClass, result.Error)
+		return
+	}
+	l.Printf("%s %s", result.Phase, result.Status)
+}
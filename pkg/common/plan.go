@@ -0,0 +1,67 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/pterm/pterm"
+)
+
+// Plan prints the operations a dry run would perform, repository by
+// repository. Problems are things the real run would fail on.
+type Plan struct {
+	command  string
+	repos    int
+	problems int
+}
+
+// NewPlan starts the dry run plan of command
+func NewPlan(command string, repos int) *Plan {
+	Printf("\n🧪 Dry run of %s for %d repositories, nothing is written locally or remotely\n", command, repos)
+	return &Plan{command: command}
+}
+
+// Repo starts the plan of a repository
+func (p *Plan) Repo(name string) {
+	p.repos++
+	Printf("\n📦 %s\n", name)
+}
+
+// Step adds a planned operation
+func (p *Plan) Step(format string, args ...any) {
+	Printf("   • %s\n", fmt.Sprintf(format, args...))
+}
+
+// Note adds a remark that doesn't stop the run
+func (p *Plan) Note(format string, args ...any) {
+	Printf("   ⚠️  %s\n", fmt.Sprintf(format, args...))
+}
+
+// Problem adds a reason the real run would fail
+func (p *Plan) Problem(format string, args ...any) {
+	p.problems++
+	Printf("   ❌ %s\n", fmt.Sprintf(format, args...))
+}
+
+// Output adds a file the run would write, outside of any repository
+func (p *Plan) Output(what, path string) {
+	Printf("\n📄 Would write %s to %s\n", what, path)
+}
+
+// Finish prints the end of the plan and returns an error when problems were
+// found
+func (p *Plan) Finish() error {
+	Printf("\n🧪 Dry run of %s planned for %d repositories, %d problems found\n", p.command, p.repos, p.problems)
+	if p.problems > 0 {
+		return fmt.Errorf("dry run of %s found %d problems", p.command, p.problems)
+	}
+	pterm.Success.Println("No changes were made, rerun without --dry-run to apply the plan")
+	return nil
+}
+
+// LFSEstimate renders the LFS size of an inventory record for a plan
+func LFSEstimate(record InventoryRecord) string {
+	if record.LFSSizeBytes > 0 {
+		return FormatBytes(record.LFSSizeBytes)
+	}
+	return "unknown size"
+}
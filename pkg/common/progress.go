@@ -0,0 +1,396 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/pterm/pterm"
+)
+
+// progressInterval is how often the live display is redrawn
+const progressInterval = 250 * time.Millisecond
+
+// phaseActivities describes what a worker does in each phase, keyed by the
+// phases of the migration state
+var phaseActivities = map[string]string{
+	"pulled":   "pulling",
+	"synced":   "syncing",
+	"verified": "verifying",
+	"compared": "comparing",
+}
+
+var (
+	progressMu sync.Mutex
+	progress   *Progress
+)
+
+// Progress follows the repositories being processed. On a terminal it is a
+// live display at the bottom of the console with a line per repository in
+// progress, below the regular output. Elsewhere, such as in Actions or when
+// output is redirected, a progress line is printed as each repository
+// finishes. Overall progress and the ETA are based on the LFS sizes of the
+// inventory, estimated from the Git sizes where they are unknown, or on
+// repository counts when no size is known.
+type Progress struct {
+	mu   sync.Mutex
+	live bool
+
+	started     time.Time
+	repos       int
+	size        int64
+	sizes       map[string]int64
+	done        int
+	doneSize    int64
+	transferred int64
+	active      []*Activity
+
+	// height is the number of lines of the display on screen, pending a
+	// partial line of output written while it is shown
+	height  int
+	pending []byte
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// Activity is the work on one repository. A nil activity records nothing,
+// for repositories processed without a progress display.
+type Activity struct {
+	progress *Progress
+	repo     string
+
+	step        string
+	objects     int
+	objectsDone int
+	bytes       int64
+	stepStarted time.Time
+}
+
+// StartProgress starts following records. The live display is only used on
+// a terminal outside of Actions.
+func StartProgress(records []InventoryRecord) *Progress {
+	p := &Progress{
+		live:    !ciMode && isTerminal(os.Stdout),
+		started: time.Now(),
+		repos:   len(records),
+		sizes:   make(map[string]int64, len(records)),
+	}
+	for _, record := range records {
+		p.sizes[record.Repository] = record.EstimatedLFSSize()
+		p.size += record.EstimatedLFSSize()
+	}
+
+	progressMu.Lock()
+	progress = p
+	progressMu.Unlock()
+
+	if p.live {
+		p.stop = make(chan struct{})
+		p.stopped = make(chan struct{})
+		go p.refresh()
+	}
+	return p
+}
+
+// Stop removes the live display. Output goes straight to the console again.
+func (p *Progress) Stop() {
+	progressMu.Lock()
+	if progress == p {
+		progress = nil
+	}
+	progressMu.Unlock()
+
+	if !p.live {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	os.Stdout.Write(p.pending)
+	p.pending = nil
+	p.live = false
+}
+
+// BeginRepo starts or continues the activity of a repository in phase
+func BeginRepo(record InventoryRecord, phase string) *Activity {
+	p := currentProgress()
+	if p == nil {
+		return nil
+	}
+
+	step := phase
+	if activity, ok := phaseActivities[phase]; ok {
+		step = activity
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, a := range p.active {
+		if a.repo == record.Repository {
+			a.setStep(step)
+			return a
+		}
+	}
+	if _, ok := p.sizes[record.Repository]; !ok {
+		p.sizes[record.Repository] = record.EstimatedLFSSize()
+	}
+	a := &Activity{progress: p, repo: record.Repository}
+	a.setStep(step)
+	p.active = append(p.active, a)
+	return a
+}
+
+// RepoProgress returns the activity of a repository in progress
+func RepoProgress(repo string) *Activity {
+	p := currentProgress()
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, a := range p.active {
+		if a.repo == repo {
+			return a
+		}
+	}
+	return nil
+}
+
+// Step describes what the repository is doing now, such as cloning
+func (a *Activity) Step(step string) {
+	if a == nil {
+		return
+	}
+	a.progress.mu.Lock()
+	defer a.progress.mu.Unlock()
+	a.setStep(step)
+}
+
+// Objects starts a step transferring a number of unique LFS objects
+func (a *Activity) Objects(step string, objects int) {
+	if a == nil {
+		return
+	}
+	a.progress.mu.Lock()
+	defer a.progress.mu.Unlock()
+	a.setStep(step)
+	a.objects = objects
+}
+
+// Object counts a finished LFS object, as an lfs.ProgressFunc
+func (a *Activity) Object(result lfs.TransferResult) {
+	if a == nil {
+		return
+	}
+	a.progress.mu.Lock()
+	defer a.progress.mu.Unlock()
+	a.objectsDone++
+}
+
+// Read adds bytes of LFS content as they are transferred to the throughput,
+// as lfs.Client.OnRead
+func (a *Activity) Read(n int64) {
+	if a == nil {
+		return
+	}
+	a.progress.mu.Lock()
+	defer a.progress.mu.Unlock()
+	a.bytes += n
+	a.progress.transferred += n
+}
+
+// Done marks the repository as finished
+func (a *Activity) Done() {
+	if a == nil {
+		return
+	}
+	p := a.progress
+
+	p.mu.Lock()
+	for i, active := range p.active {
+		if active == a {
+			p.active = append(p.active[:i], p.active[i+1:]...)
+			p.done++
+			p.doneSize += p.sizes[a.repo]
+			break
+		}
+	}
+	live, overall := p.live, p.overall()
+	p.mu.Unlock()
+
+	if !live {
+		Infof("📈 %s finished, %s\n", a.repo, overall)
+	}
+}
+
+func (a *Activity) setStep(step string) {
+	a.step = step
+	a.objects = 0
+	a.objectsDone = 0
+	a.bytes = 0
+	a.stepStarted = time.Now()
+}
+
+// line renders the activity for the live display
+func (a *Activity) line() string {
+	line := fmt.Sprintf("  %s: %s", a.repo, a.step)
+	if a.objects > 0 {
+		line += fmt.Sprintf(", %d/%d objects", a.objectsDone, a.objects)
+	}
+	if a.bytes > 0 {
+		line += fmt.Sprintf(", %s at %s/s", FormatBytes(a.bytes), FormatBytes(rate(a.bytes, time.Since(a.stepStarted))))
+	}
+	return line + fmt.Sprintf(" (%v)", time.Since(a.stepStarted).Round(time.Second))
+}
+
+// overall renders the progress of all repositories
+func (p *Progress) overall() string {
+	summary := fmt.Sprintf("%d/%d repositories done", p.done, p.repos)
+	if p.size > 0 {
+		summary += fmt.Sprintf(", %s of %s", FormatBytes(p.doneSize), FormatBytes(p.size))
+	}
+	if p.transferred > 0 {
+		summary += fmt.Sprintf(", %s/s", FormatBytes(rate(p.transferred, time.Since(p.started))))
+	}
+	if eta, ok := p.eta(); ok {
+		summary += fmt.Sprintf(", ETA %v", eta.Round(time.Second))
+	}
+	return summary
+}
+
+// eta extrapolates the time spent so far over what remains, by size when
+// the inventory has sizes. Repositories in progress count for what they
+// transferred, up to their size.
+func (p *Progress) eta() (time.Duration, bool) {
+	var fraction float64
+	if p.size > 0 {
+		done := p.doneSize
+		for _, a := range p.active {
+			done += min(a.bytes, p.sizes[a.repo])
+		}
+		fraction = float64(done) / float64(p.size)
+	} else if p.repos > 0 {
+		fraction = float64(p.done) / float64(p.repos)
+	}
+	if fraction <= 0 || fraction >= 1 {
+		return 0, false
+	}
+
+	elapsed := time.Since(p.started)
+	return time.Duration(float64(elapsed) * (1 - fraction) / fraction), true
+}
+
+// refresh redraws the live display until the progress is stopped
+func (p *Progress) refresh() {
+	defer close(p.stopped)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		p.mu.Lock()
+		p.clear()
+		p.draw()
+		p.mu.Unlock()
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// write prints console output above the live display. Partial lines are
+// held back until they are complete, so the display isn't drawn in the
+// middle of a line.
+func (p *Progress) write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.live {
+		return os.Stdout.Write(b)
+	}
+
+	p.pending = append(p.pending, b...)
+	end := bytes.LastIndexByte(p.pending, '\n')
+	if end < 0 {
+		return len(b), nil
+	}
+
+	p.clear()
+	if _, err := os.Stdout.Write(p.pending[:end+1]); err != nil {
+		return 0, err
+	}
+	p.pending = append([]byte(nil), p.pending[end+1:]...)
+	p.draw()
+	return len(b), nil
+}
+
+// clear erases the live display, leaving the cursor where it started
+func (p *Progress) clear() {
+	if p.height > 0 {
+		fmt.Fprintf(os.Stdout, "\x1b[%dA\r\x1b[J", p.height)
+		p.height = 0
+	}
+}
+
+// draw prints the live display below the output. Lines are cut to the
+// terminal width, since wrapped lines couldn't be cleared.
+func (p *Progress) draw() {
+	lines := []string{"⏳ " + p.overall()}
+	for _, a := range p.active {
+		lines = append(lines, a.line())
+	}
+
+	width := pterm.GetTerminalWidth() - 1
+	for i, line := range lines {
+		if runes := []rune(line); width > 0 && len(runes) > width {
+			lines[i] = string(runes[:width])
+		}
+	}
+
+	os.Stdout.WriteString(Redact(strings.Join(lines, "\n")) + "\n")
+	p.height = len(lines)
+}
+
+func currentProgress() *Progress {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	return progress
+}
+
+// consoleOutput is standard output, shared with the live progress display
+type consoleOutput struct{}
+
+func (consoleOutput) Write(b []byte) (int, error) {
+	if p := currentProgress(); p != nil {
+		return p.write(b)
+	}
+	return os.Stdout.Write(b)
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// rate returns bytes per second
+func rate(n int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(n) / elapsed.Seconds())
+}
@@ -0,0 +1,337 @@
+package common
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/go-github/v66/github"
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+)
+
+// Result statuses
+const (
+	ResultSucceeded = "succeeded"
+	ResultPartial   = "partial"
+	ResultFailed    = "failed"
+)
+
+// Error classes in the results report
+const (
+	ErrorClassPartial   = "partial"
+	ErrorClassAuth      = "auth"
+	ErrorClassNotFound  = "not_found"
+	ErrorClassRateLimit = "rate_limit"
+	ErrorClassServer    = "server"
+	ErrorClassNetwork   = "network"
+	ErrorClassDisk      = "disk"
+	ErrorClassGit       = "git"
+	ErrorClassMismatch  = "mismatch"
+	ErrorClassOther     = "other"
+)
+
+// Results report columns, written after the inventory columns so a results
+// file can be used as the input of a rerun
+const (
+	ColumnPhase              = "Phase"
+	ColumnStatus             = "Status"
+	ColumnStartedAt          = "StartedAt"
+	ColumnDurationSeconds    = "DurationSeconds"
+	ColumnObjectsTransferred = "ObjectsTransferred"
+	ColumnBytesTransferred   = "BytesTransferred"
+	ColumnObjectsSkipped     = "ObjectsSkipped"
+	ColumnBytesSkipped       = "BytesSkipped"
+	ColumnErrorClass         = "ErrorClass"
+	ColumnError              = "Error"
+)
+
+var resultColumns = []string{
+	ColumnPhase,
+	ColumnStatus,
+	ColumnStartedAt,
+	ColumnDurationSeconds,
+	ColumnObjectsTransferred,
+	ColumnBytesTransferred,
+	ColumnObjectsSkipped,
+	ColumnBytesSkipped,
+	ColumnErrorClass,
+	ColumnError,
+}
+
+// ClassifiedError attaches an error class to an error whose class can't be
+// told from its type, such as verification gaps
+type ClassifiedError struct {
+	Class string
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// WithClass returns err marked with class
+func WithClass(class string, err error) error {
+	return &ClassifiedError{Class: class, Err: err}
+}
+
+// RepoResult is the outcome of one repository in a command
+type RepoResult struct {
+	Record     InventoryRecord `json:"inventory"`
+	Phase      string          `json:"phase"`
+	Status     string          `json:"status"`
+	StartedAt  time.Time       `json:"started_at"`
+	Duration   time.Duration   `json:"-"`
+	Transfer   TransferStats   `json:"-"`
+	ErrorClass string          `json:"error_class,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// MarshalJSON renders durations in seconds and flattens the transfer counts
+func (r RepoResult) MarshalJSON() ([]byte, error) {
+	type plain RepoResult
+	return json.Marshal(struct {
+		plain
+		DurationSeconds    float64 `json:"duration_seconds"`
+		ObjectsTransferred int64   `json:"objects_transferred"`
+		BytesTransferred   int64   `json:"bytes_transferred"`
+		ObjectsSkipped     int64   `json:"objects_skipped"`
+		BytesSkipped       int64   `json:"bytes_skipped"`
+	}{
+		plain:              plain(r),
+		DurationSeconds:    r.Duration.Seconds(),
+		ObjectsTransferred: r.Transfer.ObjectsTransferred,
+		BytesTransferred:   r.Transfer.BytesTransferred,
+		ObjectsSkipped:     r.Transfer.ObjectsSkipped,
+		BytesSkipped:       r.Transfer.BytesSkipped,
+	})
+}
+
+// UnmarshalJSON reads a result written by MarshalJSON
+func (r *RepoResult) UnmarshalJSON(data []byte) error {
+	type plain RepoResult
+	var decoded struct {
+		plain
+		DurationSeconds    float64 `json:"duration_seconds"`
+		ObjectsTransferred int64   `json:"objects_transferred"`
+		BytesTransferred   int64   `json:"bytes_transferred"`
+		ObjectsSkipped     int64   `json:"objects_skipped"`
+		BytesSkipped       int64   `json:"bytes_skipped"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*r = RepoResult(decoded.plain)
+	r.Duration = time.Duration(decoded.DurationSeconds * float64(time.Second))
+	r.Transfer = TransferStats{
+		ObjectsTransferred: decoded.ObjectsTransferred,
+		BytesTransferred:   decoded.BytesTransferred,
+		ObjectsSkipped:     decoded.ObjectsSkipped,
+		BytesSkipped:       decoded.BytesSkipped,
+	}
+	return nil
+}
+
+// ReadResults reads a JSON results report written by WriteResults
+func ReadResults(filename string) ([]RepoResult, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading results file: %w", err)
+	}
+
+	var results []RepoResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("error parsing results file %s: %w", filename, err)
+	}
+	return results, nil
+}
+
+// NewRepoResult builds the result of a repository from the error its phase
+// returned
+func NewRepoResult(record InventoryRecord, phase string, started time.Time, err error) RepoResult {
+	result := RepoResult{
+		Record:    record,
+		Phase:     phase,
+		Status:    ResultStatus(err),
+		StartedAt: started.UTC(),
+		Duration:  time.Since(started),
+	}
+	if err != nil {
+		result.ErrorClass = ClassifyError(err)
+		result.Error = Redact(err.Error())
+	}
+	return result
+}
+
+// ResultStatus returns the status for the error of a repository
+func ResultStatus(err error) string {
+	var partial *PartialError
+	switch {
+	case errors.As(err, &partial):
+		return ResultPartial
+	case err != nil:
+		return ResultFailed
+	}
+	return ResultSucceeded
+}
+
+// ClassifyError returns a coarse class for err, so failures can be grouped
+// and retried by cause
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		return ErrorClassPartial
+	}
+
+	var rateLimit *github.RateLimitError
+	var abuse *github.AbuseRateLimitError
+	if errors.As(err, &rateLimit) || errors.As(err, &abuse) {
+		return ErrorClassRateLimit
+	}
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		return classifyStatus(ghErr.Response.StatusCode)
+	}
+	var httpErr *lfs.HTTPError
+	if errors.As(err, &httpErr) {
+		return classifyStatus(httpErr.StatusCode)
+	}
+	var objErr *lfs.ObjectError
+	if errors.As(err, &objErr) {
+		return classifyStatus(objErr.Code)
+	}
+
+	if errors.Is(err, syscall.ENOSPC) {
+		return ErrorClassDisk
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorClassNetwork
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// git reports authentication problems only in its output
+		message := strings.ToLower(err.Error())
+		if strings.Contains(message, "authentication failed") || strings.Contains(message, "permission denied") {
+			return ErrorClassAuth
+		}
+		if strings.Contains(message, "not found") {
+			return ErrorClassNotFound
+		}
+		return ErrorClassGit
+	}
+
+	return ErrorClassOther
+}
+
+func classifyStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorClassAuth
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return ErrorClassNotFound
+	case status == http.StatusTooManyRequests:
+		return ErrorClassRateLimit
+	case status >= 500:
+		return ErrorClassServer
+	}
+	return ErrorClassOther
+}
+
+// ResultsPath returns the location of a command's results report with the
+// given extension, in dir or the current directory
+func ResultsPath(dir, command, ext string) string {
+	return filepath.Join(dir, command+"_results."+ext)
+}
+
+// WriteResults writes results as CSV and JSON reports of command to dir and
+// returns the CSV path
+func WriteResults(dir, command string, results []RepoResult) (string, error) {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Record.Repository < results[j].Record.Repository
+	})
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("error creating results directory: %w", err)
+		}
+	}
+
+	csvPath := ResultsPath(dir, command, "csv")
+	if err := writeResultsCSV(csvPath, results); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding results: %w", err)
+	}
+	if err := os.WriteFile(ResultsPath(dir, command, "json"), []byte(Redact(string(data))), 0644); err != nil {
+		return "", fmt.Errorf("error writing results file: %w", err)
+	}
+
+	return csvPath, nil
+}
+
+func writeResultsCSV(filename string, results []RepoResult) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating results file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	header := append(append([]string{}, InventoryHeader...), resultColumns...)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	for _, r := range results {
+		row := append(r.Record.Row(),
+			r.Phase,
+			r.Status,
+			r.StartedAt.Format(time.RFC3339),
+			strconv.FormatFloat(r.Duration.Seconds(), 'f', 1, 64),
+			strconv.FormatInt(r.Transfer.ObjectsTransferred, 10),
+			strconv.FormatInt(r.Transfer.BytesTransferred, 10),
+			strconv.FormatInt(r.Transfer.ObjectsSkipped, 10),
+			strconv.FormatInt(r.Transfer.BytesSkipped, 10),
+			r.ErrorClass,
+			r.Error,
+		)
+		for i := range row {
+			row[i] = Redact(row[i])
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error writing result: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
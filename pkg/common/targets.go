@@ -0,0 +1,118 @@
+package common
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Target identifies the repository a source repository is migrated to
+type Target struct {
+	Owner string
+	Name  string
+}
+
+func (t Target) String() string {
+	return t.Owner + "/" + t.Name
+}
+
+// CloneURL returns the HTTPS clone URL of the target on a normalized API
+// hostname, see WebBaseURL
+func (t Target) CloneURL(hostname string) string {
+	return fmt.Sprintf("%s/%s/%s.git", WebBaseURL(hostname), t.Owner, t.Name)
+}
+
+// parseRepoNWO splits an owner/name pair. When the owner is omitted
+// defaultOwner is used.
+func parseRepoNWO(value, defaultOwner string) (Target, error) {
+	value = strings.TrimSpace(value)
+	parts := strings.Split(value, "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		if defaultOwner == "" {
+			return Target{}, fmt.Errorf("repository %q has no organization and no target organization is set", value)
+		}
+		return Target{Owner: defaultOwner, Name: parts[0]}, nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return Target{Owner: parts[0], Name: parts[1]}, nil
+	default:
+		return Target{}, fmt.Errorf("invalid repository %q, expected organization/repository", value)
+	}
+}
+
+// LoadMappings reads a mapping file of source_org/source_repo,target_org/target_repo
+// lines. Blank lines and lines starting with # are ignored. Keys are lower
+// cased since GitHub repository names are case insensitive.
+func LoadMappings(filename string) (map[string]Target, error) {
+	mappings := make(map[string]Target)
+	if filename == "" {
+		return mappings, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening mapping file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("error reading mapping file: %w", err)
+		}
+		line++
+
+		if len(record) != 2 {
+			return nil, fmt.Errorf("mapping file line %d: expected 2 columns got %d", line, len(record))
+		}
+
+		source, err := parseRepoNWO(record[0], "")
+		if err != nil {
+			// Allow a header row such as "source,target"
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("mapping file line %d: %w", line, err)
+		}
+
+		target, err := parseRepoNWO(record[1], "")
+		if err != nil {
+			return nil, fmt.Errorf("mapping file line %d: %w", line, err)
+		}
+
+		mappings[strings.ToLower(source.String())] = target
+	}
+
+	return mappings, nil
+}
+
+// ResolveTarget picks the target repository for an inventory record. The
+// mapping file wins, then the TargetRepository column, then the target
+// organization with the source repository name.
+func ResolveTarget(record InventoryRecord, mappings map[string]Target, targetOrg string) (Target, error) {
+	if owner := record.SourceOwner(); owner != "" {
+		if target, ok := mappings[strings.ToLower(owner+"/"+record.Repository)]; ok {
+			return target, nil
+		}
+	}
+
+	if record.TargetRepository != "" {
+		return parseRepoNWO(record.TargetRepository, targetOrg)
+	}
+
+	if targetOrg == "" {
+		return Target{}, fmt.Errorf("no mapping found for %s and no target organization is set", record.Repository)
+	}
+
+	return Target{Owner: targetOrg, Name: record.Repository}, nil
+}
@@ -0,0 +1,162 @@
+package common
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadMappings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]Target
+		wantErr string
+	}{
+		{
+			name:    "mappings",
+			content: "mona/api,octo/api-service\nmona/web,octo/web\n",
+			want: map[string]Target{
+				"mona/api": {Owner: "octo", Name: "api-service"},
+				"mona/web": {Owner: "octo", Name: "web"},
+			},
+		},
+		{
+			name:    "header, comments, blank lines and spaces",
+			content: "source,target\n# moved in the reorg\n\n mona/api , octo/api \n",
+			want:    map[string]Target{"mona/api": {Owner: "octo", Name: "api"}},
+		},
+		{
+			name:    "source keys are lower cased",
+			content: "Mona/API,Octo/API\n",
+			want:    map[string]Target{"mona/api": {Owner: "Octo", Name: "API"}},
+		},
+		{
+			name:    "empty file",
+			content: "",
+			want:    map[string]Target{},
+		},
+		{
+			name:    "too many columns",
+			content: "mona/api,octo/api,extra\n",
+			wantErr: "line 1: expected 2 columns got 3",
+		},
+		{
+			name:    "source without owner after the first line",
+			content: "mona/api,octo/api\napi,octo/api\n",
+			wantErr: "line 2",
+		},
+		{
+			name:    "target without owner",
+			content: "mona/api,api\n",
+			wantErr: "line 1",
+		},
+		{
+			name:    "nested target",
+			content: "mona/api,octo/team/api\n",
+			wantErr: "expected organization/repository",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mappings, err := LoadMappings(writeFile(t, "mappings.csv", tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadMappings() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMappings() error = %v", err)
+			}
+			if !reflect.DeepEqual(mappings, tt.want) {
+				t.Errorf("LoadMappings() = %v, want %v", mappings, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMappingsWithoutFile(t *testing.T) {
+	mappings, err := LoadMappings("")
+	if err != nil || len(mappings) != 0 {
+		t.Errorf("LoadMappings(\"\") = %v, %v, want no mappings", mappings, err)
+	}
+
+	if _, err := LoadMappings(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("LoadMappings() of a missing file succeeded")
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	mappings := map[string]Target{
+		"mona/api": {Owner: "octo", Name: "api-service"},
+	}
+
+	tests := []struct {
+		name      string
+		record    InventoryRecord
+		targetOrg string
+		want      Target
+		wantErr   bool
+	}{
+		{
+			name:      "mapping wins",
+			record:    InventoryRecord{Repository: "api", CloneURL: "https://github.com/mona/api.git", TargetRepository: "other/api"},
+			targetOrg: "fallback",
+			want:      Target{Owner: "octo", Name: "api-service"},
+		},
+		{
+			name:   "mapping matches case insensitively",
+			record: InventoryRecord{Repository: "API", CloneURL: "https://github.com/Mona/API.git"},
+			want:   Target{Owner: "octo", Name: "api-service"},
+		},
+		{
+			name:      "mapping of another owner doesn't apply",
+			record:    InventoryRecord{Repository: "api", CloneURL: "https://github.com/someone/api.git"},
+			targetOrg: "fallback",
+			want:      Target{Owner: "fallback", Name: "api"},
+		},
+		{
+			name:      "target column",
+			record:    InventoryRecord{Repository: "web", CloneURL: "https://github.com/mona/web.git", TargetRepository: "other/site"},
+			targetOrg: "fallback",
+			want:      Target{Owner: "other", Name: "site"},
+		},
+		{
+			name:      "target column without owner",
+			record:    InventoryRecord{Repository: "web", TargetRepository: "site"},
+			targetOrg: "fallback",
+			want:      Target{Owner: "fallback", Name: "site"},
+		},
+		{
+			name:    "target column without owner or organization",
+			record:  InventoryRecord{Repository: "web", TargetRepository: "site"},
+			wantErr: true,
+		},
+		{
+			name:      "target organization",
+			record:    InventoryRecord{Repository: "web", CloneURL: "https://github.com/mona/web.git"},
+			targetOrg: "fallback",
+			want:      Target{Owner: "fallback", Name: "web"},
+		},
+		{
+			name:    "nothing to go by",
+			record:  InventoryRecord{Repository: "web", CloneURL: "https://github.com/mona/web.git"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveTarget(tt.record, mappings, tt.targetOrg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveTarget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
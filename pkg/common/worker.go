@@ -1,34 +1,187 @@
 package common
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/mona-actions/gh-migrate-lfs/internal/metrics"
 	"github.com/pterm/pterm"
 )
 
 type ProcessStats struct {
 	Processed int32
+	Partial   int32
 	Failed    int32
 	StartTime time.Time
+
+	mu        sync.Mutex
+	transfers map[string]*TransferStats
+	results   map[string]RepoResult
+
+	// grouped folds the output of each repository in Actions logs, set
+	// when a single worker keeps the output of repositories apart
+	grouped bool
+
+	// expected are the repositories the worker pool will process, for the
+	// progress display
+	expected []InventoryRecord
+}
+
+// PartialError marks a repository that was processed but with gaps, such as
+// LFS objects missing on the source. Worker pools count it separately from
+// failures.
+type PartialError struct {
+	Err error
+}
+
+func (e *PartialError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// TransferStats counts the LFS objects moved for a repository. Skipped
+// objects were already present at the destination.
+type TransferStats struct {
+	ObjectsTransferred int64
+	BytesTransferred   int64
+	ObjectsSkipped     int64
+	BytesSkipped       int64
+}
+
+// Add accumulates other into t
+func (t *TransferStats) Add(other TransferStats) {
+	t.ObjectsTransferred += other.ObjectsTransferred
+	t.BytesTransferred += other.BytesTransferred
+	t.ObjectsSkipped += other.ObjectsSkipped
+	t.BytesSkipped += other.BytesSkipped
 }
 
 func NewProcessStats() *ProcessStats {
 	return &ProcessStats{
 		StartTime: time.Now(),
+		transfers: make(map[string]*TransferStats),
+		results:   make(map[string]RepoResult),
+	}
+}
+
+// Expect sets the repositories the worker pool will process. Their LFS sizes
+// give the overall progress and ETA.
+func (s *ProcessStats) Expect(records []InventoryRecord) {
+	s.expected = records
+}
+
+// Track runs fn as phase of a repository and records its result for the
+// results report. It is safe to call from several workers.
+func (s *ProcessStats) Track(record InventoryRecord, phase string, fn func() error) error {
+	if s.grouped {
+		StartGroup(record.Repository)
+		defer EndGroup()
+	}
+
+	activity := BeginRepo(record, phase)
+	defer activity.Done()
+
+	started := time.Now()
+	RepoLogger(record.Repository).Printf("%s started", phase)
+	err := fn()
+	s.RecordResult(NewRepoResult(record, phase, started, err))
+	return err
+}
+
+// RecordResult stores the result of a repository, replacing an earlier one,
+// and adds it to the repository log and the metrics. In Actions, failed and
+// partial results are annotated.
+func (s *ProcessStats) RecordResult(result RepoResult) {
+	logResult(result)
+	annotateResult(result)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := ""
+	if earlier, ok := s.results[result.Record.Repository]; ok && earlier.Phase == result.Phase {
+		previous = earlier.Status
 	}
+	metrics.RecordResult(result.Phase, previous, result.Status)
+	s.results[result.Record.Repository] = result
+}
+
+// Results returns the recorded results with their transfer counts
+func (s *ProcessStats) Results() []RepoResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]RepoResult, 0, len(s.results))
+	for repo, result := range s.results {
+		if t, ok := s.transfers[repo]; ok {
+			result.Transfer = *t
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// WriteResults writes the results report of command to dir and prints its
+// location. In Actions, the results are also added to the step summary.
+func (s *ProcessStats) WriteResults(dir, command string) error {
+	results := s.Results()
+	path, err := WriteResults(dir, command, results)
+	if err != nil {
+		return err
+	}
+	Printf("📄 Results: %s\n", path)
+
+	return writeStepSummary(command, results, time.Since(s.StartTime))
+}
+
+// RecordTransfer adds LFS transfer counts for a repository and to the
+// metrics. It is safe to call from several workers.
+func (s *ProcessStats) RecordTransfer(repo string, t TransferStats) {
+	metrics.RecordTransfer(t.ObjectsTransferred, t.BytesTransferred, t.ObjectsSkipped, t.BytesSkipped)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.transfers[repo] == nil {
+		s.transfers[repo] = &TransferStats{}
+	}
+	s.transfers[repo].Add(t)
+}
+
+// Transfers returns a copy of the per-repository transfer counts
+func (s *ProcessStats) Transfers() map[string]TransferStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfers := make(map[string]TransferStats, len(s.transfers))
+	for repo, t := range s.transfers {
+		transfers[repo] = *t
+	}
+	return transfers
 }
 
 func (s *ProcessStats) PrintSummary(workDir string) {
-	fmt.Printf("\n📊 Summary:\n")
-	fmt.Printf("✅ Successfully processed: %d repositories\n", s.Processed)
-	fmt.Printf("❌ Failed: %d repositories\n", s.Failed)
+	Printf("\n📊 Summary:\n")
+	Printf("✅ Successfully processed: %d repositories\n", s.Processed)
+	if s.Partial > 0 {
+		Printf("⚠️  Partially processed: %d repositories\n", s.Partial)
+	}
+	Printf("❌ Failed: %d repositories\n", s.Failed)
+	s.printTransfers()
 	if workDir != "" {
-		fmt.Printf("📁 Output directory: %s\n", workDir)
+		Printf("📁 Output directory: %s\n", workDir)
+	}
+	if dir := RepoLogsDir(); dir != "" {
+		Printf("📝 Repository logs: %s\n", dir)
 	}
-	fmt.Printf("🕐 Total time: %v\n", time.Since(s.StartTime).Round(time.Second))
+	Printf("🕐 Total time: %v\n", time.Since(s.StartTime).Round(time.Second))
 }
 
 // WorkerPool manages a pool of workers processing repository operations
@@ -39,16 +192,33 @@ func WorkerPool[T any](
 	processFunc func(T) error,
 ) error {
 	var wg sync.WaitGroup
-	spinner, _ := pterm.DefaultSpinner.Start("Processing repositories...")
+	progress := StartProgress(stats.expected)
+
+	// Output of concurrent workers interleaves, so it's folded as a whole
+	// rather than per repository
+	stats.grouped = CIMode() && maxWorkers <= 1
+	if CIMode() && !stats.grouped {
+		StartGroup(fmt.Sprintf("Processing repositories with %d workers", maxWorkers))
+	}
 
 	// Start worker pool
+	metrics.AddWorkers(maxWorkers)
+	defer metrics.AddWorkers(-maxWorkers)
 	for i := 0; i < maxWorkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				if err := processFunc(job); err != nil {
-					fmt.Printf("Error processing: %v\n", err)
+				idle := metrics.Busy()
+				err := processFunc(job)
+				idle()
+
+				var partial *PartialError
+				if errors.As(err, &partial) {
+					Printf("Partially processed: %v\n", err)
+					atomic.AddInt32(&stats.Partial, 1)
+				} else if err != nil {
+					Printf("Error processing: %v\n", err)
 					atomic.AddInt32(&stats.Failed, 1)
 				} else {
 					atomic.AddInt32(&stats.Processed, 1)
@@ -59,7 +229,11 @@ func WorkerPool[T any](
 
 	// Wait for all workers to complete
 	wg.Wait()
-	spinner.Success()
+	if CIMode() && !stats.grouped {
+		EndGroup()
+	}
+	progress.Stop()
+	pterm.Success.Println("Processing repositories...")
 
 	if stats.Failed > 0 {
 		return fmt.Errorf("failed to process %d repositories", stats.Failed)
@@ -67,3 +241,30 @@ func WorkerPool[T any](
 
 	return nil
 }
+
+// printTransfers prints LFS object counts per repository and in total, when
+// any were recorded
+func (s *ProcessStats) printTransfers() {
+	transfers := s.Transfers()
+	if len(transfers) == 0 {
+		return
+	}
+
+	repos := make([]string, 0, len(transfers))
+	for repo := range transfers {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	var total TransferStats
+	Printf("📦 LFS objects:\n")
+	for _, repo := range repos {
+		t := transfers[repo]
+		total.Add(t)
+		Printf("   %s: %d transferred (%s), %d skipped (%s)\n", repo,
+			t.ObjectsTransferred, FormatBytes(t.BytesTransferred),
+			t.ObjectsSkipped, FormatBytes(t.BytesSkipped))
+	}
+	Printf("📦 Total LFS objects transferred: %d (%s)\n", total.ObjectsTransferred, FormatBytes(total.BytesTransferred))
+	Printf("⏭️  Total LFS objects skipped, already present: %d (%s)\n", total.ObjectsSkipped, FormatBytes(total.BytesSkipped))
+}
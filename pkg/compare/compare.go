@@ -0,0 +1,489 @@
+package compare
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// ErrDifferences is returned when source and target LFS content differ
+var ErrDifferences = common.WithClass(common.ErrorClassMismatch, errors.New("source and target LFS content differ"))
+
+// Difference kinds
+const (
+	OnlyInSource = "only-in-source"
+	OnlyInTarget = "only-in-target"
+	SizeMismatch = "size-mismatch"
+)
+
+// Output formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// compareRefPrefix is where source and target refs are fetched to in the
+// local clone while comparing, removed again afterwards
+const compareRefPrefix = "refs/lfs-compare/"
+
+// Difference is one object that isn't identical on both sides
+type Difference struct {
+	Kind       string `json:"kind"`
+	OID        string `json:"oid"`
+	SourceSize int64  `json:"source_size,omitempty"`
+	TargetSize int64  `json:"target_size,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Ref        string `json:"ref,omitempty"`
+}
+
+// Result is the comparison of one repository
+type Result struct {
+	Repository    string       `json:"repository"`
+	Source        string       `json:"source"`
+	Target        string       `json:"target"`
+	Refs          []string     `json:"refs"`
+	SourceOnlyRef []string     `json:"source_only_refs,omitempty"`
+	TargetOnlyRef []string     `json:"target_only_refs,omitempty"`
+	SourceObjects int          `json:"source_objects"`
+	TargetObjects int          `json:"target_objects"`
+	Differences   []Difference `json:"differences"`
+	Error         string       `json:"error,omitempty"`
+}
+
+// Count returns the number of differences of a kind
+func (r Result) Count(kind string) int {
+	count := 0
+	for _, d := range r.Differences {
+		if d.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+type compareJob struct {
+	record common.InventoryRecord
+	target common.Target
+}
+
+// CompareFromCSV compares the LFS pointers of every inventory repository on
+// the source and the target and writes the differences to a report
+func CompareFromCSV() error {
+	inputFile := viper.GetString("GHMLFS_FILE")
+	mappingFile := viper.GetString("GHMLFS_MAPPING_FILE")
+	workDir := viper.GetString("GHMLFS_WORK_DIR")
+	sourceToken := viper.GetString("GHMLFS_SOURCE_TOKEN")
+	targetOrg := viper.GetString("GHMLFS_TARGET_ORGANIZATION")
+	targetHostname := viper.GetString("GHMLFS_TARGET_HOSTNAME")
+	targetToken := viper.GetString("GHMLFS_TARGET_TOKEN")
+	maxWorkers := viper.GetInt("GHMLFS_WORKERS")
+	format := strings.ToLower(viper.GetString("GHMLFS_COMPARE_FORMAT"))
+	outputFile := viper.GetString("GHMLFS_COMPARE_OUTPUT")
+	common.RegisterSecrets(sourceToken, targetToken)
+	common.ConfigureRepoLogs(workDir)
+
+	if format == "" {
+		format = FormatCSV
+	}
+	if format != FormatCSV && format != FormatJSON {
+		return fmt.Errorf("invalid format %q, expected %s or %s", format, FormatCSV, FormatJSON)
+	}
+	if outputFile == "" {
+		outputFile = "lfs_compare." + format
+	}
+
+	records, err := common.ReadInventory(inputFile)
+	if err != nil {
+		return err
+	}
+
+	store, mode, err := state.OpenForRun(workDir)
+	if err != nil {
+		return err
+	}
+	records = store.Select(records, state.PhaseCompared, mode)
+
+	mappings, err := common.LoadMappings(mappingFile)
+	if err != nil {
+		return err
+	}
+
+	var compareJobs []compareJob
+	for _, record := range records {
+		target, err := common.ResolveTarget(record, mappings, targetOrg)
+		if err != nil {
+			return fmt.Errorf("failed to resolve target for %s: %w", record.Repository, err)
+		}
+		compareJobs = append(compareJobs, compareJob{record: record, target: target})
+	}
+
+	if viper.GetBool("GHMLFS_DRY_RUN") {
+		return planCompare(compareJobs, workDir, targetHostname, outputFile)
+	}
+
+	jobs := make(chan compareJob)
+	go func() {
+		defer close(jobs)
+		for _, job := range compareJobs {
+			jobs <- job
+		}
+	}()
+
+	var mu sync.Mutex
+	var results []Result
+	stats := common.NewProcessStats()
+	stats.Expect(records)
+	process := func(job compareJob) error {
+		result, err := CompareRepository(
+			filepath.Join(workDir, job.record.Repository),
+			job.record, job.target.CloneURL(targetHostname),
+			sourceToken, targetToken,
+		)
+		result.Target = job.target.String()
+		if err != nil {
+			result.Error = common.Redact(err.Error())
+		}
+
+		mu.Lock()
+		results = append(results, result)
+		mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+		if len(result.Differences) > 0 {
+			return fmt.Errorf("%s: %w", job.record.Repository, ErrDifferences)
+		}
+		return nil
+	}
+	poolErr := common.WorkerPool(jobs, maxWorkers, stats, func(job compareJob) error {
+		return stats.Track(job.record, state.PhaseCompared, func() error {
+			return store.Track(job.record.Repository, state.PhaseCompared, func() error {
+				return process(job)
+			})
+		})
+	})
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Repository < results[j].Repository
+	})
+
+	printSummary(results)
+
+	if format == FormatJSON {
+		err = writeJSON(outputFile, results)
+	} else {
+		err = writeCSV(outputFile, results)
+	}
+	if err != nil {
+		return err
+	}
+
+	stats.PrintSummary(workDir)
+	common.Printf("📄 Comparison report: %s\n", outputFile)
+	if err := stats.WriteResults(workDir, "compare"); err != nil {
+		return err
+	}
+
+	// Only differences are reported as such, other failures keep their own error
+	if poolErr != nil {
+		differing := 0
+		for _, r := range results {
+			if len(r.Differences) > 0 {
+				differing++
+			}
+		}
+		if differing > 0 {
+			return fmt.Errorf("%w in %d repositories: %v", ErrDifferences, differing, poolErr)
+		}
+		return poolErr
+	}
+
+	common.Println("\n✅ Source and target LFS content is identical!")
+	return nil
+}
+
+// CompareRepository fetches the source and target refs into the local clone
+// at repoPath and diffs the LFS pointers reachable from the refs both sides
+// have in common
+func CompareRepository(repoPath string, record common.InventoryRecord, targetURL, sourceToken, targetToken string) (Result, error) {
+	result := Result{Repository: record.Repository, Source: record.CloneURL}
+
+	if _, err := os.Stat(repoPath); err != nil {
+		return result, fmt.Errorf("❌ Local clone of %s not found, run pull first: %w", record.Repository, err)
+	}
+
+	sourceURL, err := common.ValidateCloneURL(record.CloneURL, record.SourceHost)
+	if err != nil {
+		return result, fmt.Errorf("❌ Invalid clone URL for %s: %w", record.Repository, err)
+	}
+
+	defer deleteCompareRefs(repoPath)
+
+	if err := fetchRefs(repoPath, sourceURL.String(), "source", sourceToken); err != nil {
+		return result, err
+	}
+	if err := fetchRefs(repoPath, targetURL, "target", targetToken); err != nil {
+		return result, err
+	}
+
+	sourceRefs, err := listCompareRefs(repoPath, "source")
+	if err != nil {
+		return result, err
+	}
+	targetRefs, err := listCompareRefs(repoPath, "target")
+	if err != nil {
+		return result, err
+	}
+
+	// Only refs present on both sides are compared, the rest are reported
+	var sourceScan, targetScan []string
+	for name := range sourceRefs {
+		if _, ok := targetRefs[name]; ok {
+			result.Refs = append(result.Refs, name)
+		} else {
+			result.SourceOnlyRef = append(result.SourceOnlyRef, name)
+		}
+	}
+	for name := range targetRefs {
+		if _, ok := sourceRefs[name]; !ok {
+			result.TargetOnlyRef = append(result.TargetOnlyRef, name)
+		}
+	}
+	sort.Strings(result.Refs)
+	sort.Strings(result.SourceOnlyRef)
+	sort.Strings(result.TargetOnlyRef)
+
+	if len(result.Refs) == 0 {
+		return result, nil
+	}
+
+	for _, name := range result.Refs {
+		sourceScan = append(sourceScan, compareRefPrefix+"source/"+name)
+		targetScan = append(targetScan, compareRefPrefix+"target/"+name)
+	}
+
+	sourcePointers, err := lfs.ScanPointers(repoPath, sourceScan)
+	if err != nil {
+		return result, fmt.Errorf("❌ Failed to scan source pointers of %s: %w", record.Repository, err)
+	}
+	targetPointers, err := lfs.ScanPointers(repoPath, targetScan)
+	if err != nil {
+		return result, fmt.Errorf("❌ Failed to scan target pointers of %s: %w", record.Repository, err)
+	}
+
+	result.SourceObjects = len(sourcePointers)
+	result.TargetObjects = len(targetPointers)
+	result.Differences = diffPointers(sourcePointers, targetPointers)
+
+	return result, nil
+}
+
+// diffPointers compares pointers by object ID
+func diffPointers(source, target []lfs.Pointer) []Difference {
+	targetByOID := make(map[string]lfs.Pointer, len(target))
+	for _, p := range target {
+		targetByOID[p.OID] = p
+	}
+	sourceByOID := make(map[string]lfs.Pointer, len(source))
+	for _, p := range source {
+		sourceByOID[p.OID] = p
+	}
+
+	var differences []Difference
+	for _, s := range source {
+		t, ok := targetByOID[s.OID]
+		switch {
+		case !ok:
+			differences = append(differences, Difference{Kind: OnlyInSource, OID: s.OID, SourceSize: s.Size, Path: s.Path, Ref: displayRef(s.Ref)})
+		case t.Size != s.Size:
+			differences = append(differences, Difference{Kind: SizeMismatch, OID: s.OID, SourceSize: s.Size, TargetSize: t.Size, Path: s.Path, Ref: displayRef(s.Ref)})
+		}
+	}
+	for _, t := range target {
+		if _, ok := sourceByOID[t.OID]; !ok {
+			differences = append(differences, Difference{Kind: OnlyInTarget, OID: t.OID, TargetSize: t.Size, Path: t.Path, Ref: displayRef(t.Ref)})
+		}
+	}
+
+	return differences
+}
+
+// displayRef strips the temporary namespace from a scanned ref
+func displayRef(ref string) string {
+	ref = strings.TrimPrefix(ref, compareRefPrefix)
+	ref = strings.TrimPrefix(ref, "source/")
+	return strings.TrimPrefix(ref, "target/")
+}
+
+// fetchRefs fetches the branches and tags of a remote into the comparison
+// namespace. The token is passed as an HTTP header through the environment
+// so it never appears in URLs, arguments or git config files.
+func fetchRefs(repoPath, remoteURL, side, token string) error {
+	prefix := compareRefPrefix + side + "/"
+	cmd := exec.Command("git", "fetch", "--no-tags", "--quiet", remoteURL,
+		"+refs/heads/*:"+prefix+"heads/*",
+		"+refs/tags/*:"+prefix+"tags/*",
+	)
+	cmd.Dir = repoPath
+	cmd.Env = append(append(os.Environ(), authEnv(token)...), common.GitTraceEnv()...)
+	if output, err := common.RepoLogger(filepath.Base(repoPath)).Run(cmd); err != nil {
+		return fmt.Errorf("❌ Failed to fetch %s refs: %s, %w", side, common.Redact(string(output)), err)
+	}
+	return nil
+}
+
+// authEnv configures an Authorization header for git over HTTPS
+func authEnv(token string) []string {
+	credentials := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+	return []string{
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_LFS_SKIP_SMUDGE=1",
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic " + credentials,
+	}
+}
+
+// listCompareRefs returns the fetched refs of one side keyed by their name
+// relative to the side, e.g. heads/main
+func listCompareRefs(repoPath, side string) (map[string]string, error) {
+	prefix := compareRefPrefix + side + "/"
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname) %(objectname)", prefix)
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s refs: %w", side, err)
+	}
+
+	refs := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		refs[strings.TrimPrefix(fields[0], prefix)] = fields[1]
+	}
+	return refs, nil
+}
+
+// deleteCompareRefs removes the temporary refs so they don't get pushed or
+// scanned by later commands
+func deleteCompareRefs(repoPath string) {
+	list := exec.Command("git", "for-each-ref", "--format=delete %(refname)", compareRefPrefix)
+	list.Dir = repoPath
+	output, err := list.Output()
+	if err != nil || len(output) == 0 {
+		return
+	}
+
+	update := exec.Command("git", "update-ref", "--stdin")
+	update.Dir = repoPath
+	update.Stdin = strings.NewReader(string(output))
+	update.Run()
+}
+
+func printSummary(results []Result) {
+	if len(results) == 0 {
+		return
+	}
+
+	data := pterm.TableData{{"Repository", "Target", "Refs", "Source objects", "Target objects", "Only in source", "Only in target", "Size mismatch"}}
+	for _, r := range results {
+		refs := strconv.Itoa(len(r.Refs))
+		if r.Error != "" {
+			refs = "error"
+		}
+		data = append(data, []string{
+			r.Repository,
+			r.Target,
+			refs,
+			strconv.Itoa(r.SourceObjects),
+			strconv.Itoa(r.TargetObjects),
+			strconv.Itoa(r.Count(OnlyInSource)),
+			strconv.Itoa(r.Count(OnlyInTarget)),
+			strconv.Itoa(r.Count(SizeMismatch)),
+		})
+	}
+	common.Println()
+	pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+
+	for _, r := range results {
+		if len(r.SourceOnlyRef) > 0 {
+			common.Warnf("⚠️  %s: %d refs only on the source were not compared: %s\n", r.Repository, len(r.SourceOnlyRef), strings.Join(r.SourceOnlyRef, ", "))
+		}
+		if len(r.TargetOnlyRef) > 0 {
+			common.Warnf("⚠️  %s: %d refs only on the target were not compared: %s\n", r.Repository, len(r.TargetOnlyRef), strings.Join(r.TargetOnlyRef, ", "))
+		}
+	}
+}
+
+func writeJSON(filename string, results []Result) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(common.NewRedactingWriter(file))
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(results); err != nil {
+		return fmt.Errorf("error writing comparison report: %w", err)
+	}
+	return nil
+}
+
+func writeCSV(filename string, results []Result) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"Repository", "Target", "Kind", "OID", "SourceSize", "TargetSize", "Path", "Ref", "Error"}); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	for _, r := range results {
+		if r.Error != "" {
+			if err := writer.Write([]string{r.Repository, r.Target, "error", "", "", "", "", "", r.Error}); err != nil {
+				return fmt.Errorf("error writing comparison report: %w", err)
+			}
+			continue
+		}
+		for _, d := range r.Differences {
+			if err := writer.Write([]string{
+				r.Repository,
+				r.Target,
+				d.Kind,
+				d.OID,
+				strconv.FormatInt(d.SourceSize, 10),
+				strconv.FormatInt(d.TargetSize, 10),
+				d.Path,
+				d.Ref,
+				"",
+			}); err != nil {
+				return fmt.Errorf("error writing comparison report: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
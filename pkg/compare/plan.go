@@ -0,0 +1,35 @@
+package compare
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+// planCompare prints the fetches and the report a comparison would make
+func planCompare(jobs []compareJob, workDir, targetHostname, outputFile string) error {
+	plan := common.NewPlan("compare", len(jobs))
+	for _, job := range jobs {
+		plan.Repo(job.record.Repository + " → " + job.target.String())
+
+		repoPath := filepath.Join(workDir, job.record.Repository)
+		if _, err := os.Stat(repoPath); err != nil {
+			plan.Problem("local clone %s not found, run pull first", repoPath)
+			continue
+		}
+
+		sourceURL, err := common.ValidateCloneURL(job.record.CloneURL, job.record.SourceHost)
+		if err != nil {
+			plan.Problem("invalid clone URL: %v", err)
+			continue
+		}
+
+		plan.Step("git fetch the branches and tags of %s into %ssource/", sourceURL, compareRefPrefix)
+		plan.Step("git fetch the branches and tags of %s into %starget/", job.target.CloneURL(targetHostname), compareRefPrefix)
+		plan.Step("diff the LFS pointers of the refs both sides have, then delete the fetched refs")
+	}
+
+	plan.Output("the comparison report", outputFile)
+	return plan.Finish()
+}
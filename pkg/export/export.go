@@ -1,24 +1,27 @@
 package export
 
 import (
-	"encoding/csv"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"time"
 
 	"github.com/mona-actions/gh-migrate-lfs/internal/api"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
 	"github.com/pterm/pterm"
 	"github.com/spf13/viper"
 )
 
-// RepoLFSInfo holds information about a repository containing LFS data
-type RepoLFSInfo struct {
-	Name     string
-	Path     string
-	CloneURL string
+func ExportLFSRepos() error {
+	_, err := Export()
+	return err
 }
 
-func ExportLFSRepos() error {
+// Export writes the inventory of the source organization's repositories
+// with LFS content and returns its records
+func Export() ([]common.InventoryRecord, error) {
 	start := time.Now()
 	spinner, _ := pterm.DefaultSpinner.Start("Searching for repositories with LFS content...")
 
@@ -29,99 +32,147 @@ func ExportLFSRepos() error {
 	hostname := viper.GetString("GHMLFS_SOURCE_HOSTNAME")
 
 	if organization == "" || token == "" {
-		return fmt.Errorf("missing required parameters: organization, token")
+		return nil, fmt.Errorf("missing required parameters: organization, token")
 	}
 
 	if depth == 0 {
 		depth = 1 // Default depth if not specified
 	}
 
+	outputFile := organization + "_lfs.csv"
+	store, mode, err := state.OpenForRun(viper.GetString("GHMLFS_WORK_DIR"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Reruns keep the rows of repositories that aren't checked again
+	previous := make(map[string]common.InventoryRecord)
+	if mode != state.SelectAll {
+		records, err := common.ReadInventory(outputFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		for _, record := range records {
+			previous[record.Repository] = record
+		}
+	}
+
 	// Fetch repositories
 	pterm.Info.Printf("Fetching repository list for %s...", organization)
 	repos, err := api.GetRepositories(organization, token, hostname)
 	if err != nil {
-		return fmt.Errorf("failed to fetch repositories: %w", err)
+		return nil, fmt.Errorf("failed to fetch repositories: %w", err)
 	}
 	pterm.Info.Printf("Found %d repositories\n", len(repos))
 
 	// Process repositories and collect LFS information
-	var lfsRepos []RepoLFSInfo
+	var lfsRepos []common.InventoryRecord
 	var successful, failed, found int
 
 	pterm.Info.Printf("Checking repositories for LFS content (searching up to depth %d)...", depth)
 
-	for _, repo := range repos {
+	var skipped int
+	stats := common.NewProcessStats()
+	for _, r := range repos {
+		repo := r.GetName()
+		if !store.Selected(repo, state.PhaseExported, mode) {
+			if record, ok := previous[repo]; ok {
+				lfsRepos = append(lfsRepos, record)
+				found++
+			}
+			skipped++
+			continue
+		}
+
+		common.StartGroup(repo)
 		pterm.Info.Printf("Searching repository contents: '%s'...\n", repo)
 
+		// Use the URLs reported by the API, the configured hostname is the
+		// REST endpoint and can't be cloned from on GHES
+		cloneURL := r.GetCloneURL()
+		sourceHost := ""
+		if parsed, err := url.Parse(cloneURL); err == nil {
+			sourceHost = parsed.Host
+		}
+
+		// Older GHES versions don't report visibility
+		visibility := r.GetVisibility()
+		if visibility == "" {
+			visibility = "public"
+			if r.GetPrivate() {
+				visibility = "private"
+			}
+		}
+
+		record := common.InventoryRecord{
+			Repository:       repo,
+			CloneURL:         cloneURL,
+			SSHURL:           r.GetSSHURL(),
+			SourceHost:       sourceHost,
+			Visibility:       visibility,
+			DefaultBranch:    r.GetDefaultBranch(),
+			Description:      r.GetDescription(),
+			RepositorySizeKB: int64(r.GetSize()),
+		}
+
+		started := time.Now()
+		store.Start(repo, state.PhaseExported)
 		hasLFS, path, err := api.CheckGitAttributes(organization, repo, token, depth, hostname)
+		store.Finish(repo, state.PhaseExported, err)
+		record.GitAttributesPath = path
 		if err != nil {
 			pterm.Info.Printf("Warning: Failed to determine LFS status for repo %s: %v", repo, err)
+		} else if hasLFS {
+			pterm.Success.Printf("LFS filter matched for repository '%s' (path: %s)\n", repo, path)
+		}
+		common.EndGroup()
+
+		stats.RecordResult(common.NewRepoResult(record, state.PhaseExported, started, err))
+		if err != nil {
 			failed++
 			continue
 		}
 
 		if hasLFS {
-			cloneURL := fmt.Sprintf("https://github.com/%s/%s.git", organization, repo)
-			if hostname != "" {
-				cloneURL = fmt.Sprintf("%s/%s/%s.git", hostname, organization, repo)
-			}
-
-			lfsRepos = append(lfsRepos, RepoLFSInfo{
-				Name:     repo,
-				Path:     path,
-				CloneURL: cloneURL,
-			})
+			lfsRepos = append(lfsRepos, record)
 			found++
-			pterm.Success.Printf("LFS filter matched for repository '%s' (path: %s)\n", repo, path)
 		}
 
 		successful++
 	}
 
-	// Write results to CSV file
-	outputFile := viper.GetString("GHMLFS_SOURCE_ORGANIZATION") + "_lfs.csv"
-	if err := writeToCSV(outputFile, lfsRepos); err != nil {
-		return fmt.Errorf("failed to write CSV file: %w", err)
+	// Write results to CSV file, a dry run only lists what it would hold
+	dryRun := viper.GetBool("GHMLFS_DRY_RUN")
+	if !dryRun {
+		if err := common.WriteInventory(outputFile, lfsRepos); err != nil {
+			return nil, fmt.Errorf("failed to write CSV file: %w", err)
+		}
 	}
 	spinner.Success()
 
-	fmt.Printf("\n📊 Export Summary:\n")
-	fmt.Printf("Total repositories found: %d\n", len(repos))
-	fmt.Printf("✅ Successfully processed: %d repositories\n", successful)
-	fmt.Printf("❌ Failed to process: %d repositories\n", failed)
-	fmt.Printf("🔍 Maximum search depth: %d\n", depth)
-	fmt.Printf("🔍 Repositories with LFS: %d\n", found)
-	fmt.Printf("📁 Output file: %s\n", outputFile)
-	fmt.Printf("🕐 Total time: %v\n", time.Since(start).Round(time.Second))
-
-	return nil
-}
-
-func writeToCSV(filename string, repos []RepoLFSInfo) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("error creating output file: %w", err)
+	common.Printf("\n📊 Export Summary:\n")
+	common.Printf("Total repositories found: %d\n", len(repos))
+	common.Printf("✅ Successfully processed: %d repositories\n", successful)
+	common.Printf("❌ Failed to process: %d repositories\n", failed)
+	if mode != state.SelectAll {
+		common.Printf("⏭️  Skipped (%s): %d repositories\n", mode, skipped)
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write header
-	if err := writer.Write([]string{"Repository", "GitAttributesPaths", "CloneURL"}); err != nil {
-		return fmt.Errorf("error writing header: %w", err)
-	}
-
-	// Write data
-	for _, repo := range repos {
-		if err := writer.Write([]string{
-			repo.Name,
-			repo.Path,
-			repo.CloneURL,
-		}); err != nil {
-			return fmt.Errorf("error writing repository data: %w", err)
+	common.Printf("🔍 Maximum search depth: %d\n", depth)
+	common.Printf("🔍 Repositories with LFS: %d\n", found)
+	common.Printf("📁 Output file: %s\n", outputFile)
+	common.Printf("🕐 Total time: %v\n", time.Since(start).Round(time.Second))
+	if dryRun {
+		plan := common.NewPlan("export", len(lfsRepos))
+		for _, record := range lfsRepos {
+			plan.Repo(record.Repository)
+			plan.Step("add %s to the inventory (.gitattributes: %s)", record.CloneURL, record.GitAttributesPath)
 		}
+		plan.Output("the inventory", outputFile)
+		return lfsRepos, plan.Finish()
+	}
+	if err := stats.WriteResults(viper.GetString("GHMLFS_WORK_DIR"), "export"); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return lfsRepos, nil
 }
@@ -0,0 +1,118 @@
+package migrate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/export"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/pull"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/sync"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// Failure policies for --on-failure
+const (
+	// OnFailureStop stops after a stage in which any repository failed
+	OnFailureStop = "stop"
+	// OnFailureSkip continues with the repositories that passed the stage
+	OnFailureSkip = "skip"
+	// OnFailureContinue continues with every repository of the inventory
+	OnFailureContinue = "continue"
+)
+
+// MigrateLFS runs export, pull and sync for an organization in one go. The
+// inventory is handed from stage to stage in memory, and the migration state
+// in the work dir tells which repositories passed each stage. With
+// GHMLFS_VERIFY set, sync verifies every repository on the target.
+func MigrateLFS() error {
+	start := time.Now()
+	workDir := viper.GetString("GHMLFS_WORK_DIR")
+	policy := viper.GetString("GHMLFS_ON_FAILURE")
+
+	if policy == "" {
+		policy = OnFailureStop
+	}
+	if policy != OnFailureStop && policy != OnFailureSkip && policy != OnFailureContinue {
+		return fmt.Errorf("invalid failure policy %q, expected %s, %s or %s", policy, OnFailureStop, OnFailureSkip, OnFailureContinue)
+	}
+
+	// A dry run plans every stage for every repository, whatever the policy
+	dryRun := viper.GetBool("GHMLFS_DRY_RUN")
+	if dryRun {
+		policy = OnFailureContinue
+	}
+
+	stages := "export → pull → sync"
+	if viper.GetBool("GHMLFS_VERIFY") {
+		stages += " → verify"
+	}
+	pterm.DefaultSection.Printf("Migrating LFS content: %s (on failure: %s)\n", stages, policy)
+
+	pterm.DefaultSection.Println("Stage 1/3: export")
+	records, err := export.Export()
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	if len(records) == 0 {
+		common.Println("\n✅ No repositories with LFS content, nothing to migrate")
+		return nil
+	}
+
+	pterm.DefaultSection.Println("Stage 2/3: pull")
+	records, err = afterStage(pull.PullRecords(records), records, workDir, state.PhasePulled, policy)
+	if err != nil {
+		return err
+	}
+
+	// Failures of the last stage always fail the migration
+	pterm.DefaultSection.Println("Stage 3/3: sync")
+	if err := sync.SyncRecords(records); err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+
+	common.Printf("\n🕐 Total migration time: %v\n", time.Since(start).Round(time.Second))
+	if dryRun {
+		common.Println("\n✅ Migration dry run completed, nothing was changed")
+		return nil
+	}
+	common.Println("\n✅ Migration completed successfully!")
+	return nil
+}
+
+// afterStage applies the failure policy to the result of a stage and returns
+// the records the next stage works on
+func afterStage(stageErr error, records []common.InventoryRecord, workDir, phase, policy string) ([]common.InventoryRecord, error) {
+	if stageErr == nil {
+		return records, nil
+	}
+
+	switch policy {
+	case OnFailureContinue:
+		pterm.Warning.Printf("Stage %s had failures, continuing with all %d repositories: %v\n", phase, len(records), stageErr)
+		return records, nil
+
+	case OnFailureSkip:
+		store, err := state.Open(workDir)
+		if err != nil {
+			return nil, err
+		}
+
+		var passed []common.InventoryRecord
+		for _, record := range records {
+			if p, ok := store.Get(record.Repository, phase); ok && (p.Status == state.StatusSucceeded || p.Status == state.StatusPartial) {
+				passed = append(passed, record)
+			}
+		}
+		if len(passed) == 0 {
+			return nil, fmt.Errorf("no repositories passed the %s stage: %w", phase, stageErr)
+		}
+
+		pterm.Warning.Printf("Stage %s had failures, continuing with the %d of %d repositories that passed\n", phase, len(passed), len(records))
+		return passed, nil
+	}
+
+	return nil, fmt.Errorf("stopping after the %s stage: %w", phase, stageErr)
+}
@@ -0,0 +1,130 @@
+package pull
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/pterm/pterm"
+)
+
+// Disk check modes for --disk-check
+const (
+	DiskCheckRefuse = "refuse"
+	DiskCheckWarn   = "warn"
+	DiskCheckOff    = "off"
+)
+
+// diskPollInterval is how often paused workers check free space again
+const diskPollInterval = 30 * time.Second
+
+// footprint is the estimated disk space a pull needs
+type footprint struct {
+	workDir int64
+	store   int64
+	// repos is the number of repositories still to be cloned
+	repos int
+	// unknownLFS is the number of those without an LFS size in the inventory
+	unknownLFS int
+}
+
+// estimateFootprint adds up the estimated footprint of repositories that
+// haven't been cloned yet. With a shared store the stored copy of LFS objects
+// lands there instead of in the work dir.
+func estimateFootprint(records []common.InventoryRecord, workDir, sharedStore string) footprint {
+	var f footprint
+	for _, record := range records {
+		if _, err := os.Stat(filepath.Join(workDir, record.Repository)); err == nil {
+			continue
+		}
+		f.repos++
+		if record.LFSSizeBytes == 0 {
+			f.unknownLFS++
+		}
+
+		if sharedStore != "" {
+			f.workDir += record.EstimatedFootprint() - record.EstimatedLFSSize()
+			f.store += record.EstimatedLFSSize()
+		} else {
+			f.workDir += record.EstimatedFootprint()
+		}
+	}
+	return f
+}
+
+// checkDiskSpace compares the estimated footprint plus minFree with the free
+// space of the work dir, and of the shared store when one is used. In refuse
+// mode a shortfall is an error, in warn mode it's only reported.
+func checkDiskSpace(records []common.InventoryRecord, workDir, sharedStore, mode string, minFree int64) error {
+	if mode == DiskCheckOff {
+		return nil
+	}
+
+	f := estimateFootprint(records, workDir, sharedStore)
+	common.Printf("\n💾 Estimated disk space for %d repositories to clone: %s\n", f.repos, common.FormatBytes(f.workDir+f.store))
+	if f.unknownLFS > 0 {
+		pterm.Warning.Printf("%d repositories have no LFS size in the inventory, their LFS objects are estimated as large as their Git history\n", f.unknownLFS)
+	}
+
+	var shortfalls int
+	check := func(label, dir string, needed int64) error {
+		free, err := common.FreeSpace(dir)
+		if errors.Is(err, common.ErrDiskSpaceUnsupported) {
+			pterm.Warning.Printf("Skipping disk space check: %v\n", err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check free space of %s: %w", dir, err)
+		}
+
+		required := needed + minFree
+		common.Printf("   %s %s: %s needed, %s free\n", label, dir, common.FormatBytes(required), common.FormatBytes(int64(free)))
+		if required > int64(free) {
+			shortfalls++
+			pterm.Warning.Printf("%s %s is short by %s\n", label, dir, common.FormatBytes(required-int64(free)))
+		}
+		return nil
+	}
+
+	if err := check("Work dir", workDir, f.workDir); err != nil {
+		return err
+	}
+	if sharedStore != "" {
+		if err := check("Shared store", sharedStore, f.store); err != nil {
+			return err
+		}
+	}
+
+	if shortfalls > 0 && mode == DiskCheckRefuse {
+		return fmt.Errorf("not enough free disk space for the pull, free up space or use --disk-check=warn to start anyway")
+	}
+	return nil
+}
+
+// waitForSpace blocks while the filesystem holding dir has less than
+// minFree bytes available, so workers don't fill the volume mid-run
+func waitForSpace(repoName, dir string, minFree int64) {
+	if minFree <= 0 {
+		return
+	}
+
+	paused := false
+	for {
+		free, err := common.FreeSpace(dir)
+		if err != nil || int64(free) >= minFree {
+			if paused {
+				pterm.Info.Printf("Free space of %s recovered, resuming '%s'\n", dir, repoName)
+			}
+			return
+		}
+		if !paused {
+			pterm.Warning.Printf("Only %s free on %s (minimum %s), pausing '%s' until space is available\n",
+				common.FormatBytes(int64(free)), dir, common.FormatBytes(minFree), repoName)
+			paused = true
+		}
+		time.Sleep(diskPollInterval)
+	}
+}
@@ -0,0 +1,102 @@
+package pull
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/pterm/pterm"
+)
+
+// CheckIntegrity hashes every LFS object referenced by a repository against
+// its object ID. Corrupt objects are removed so that the next pull downloads
+// them again. Objects listed in dangling are missing on the source and are
+// left out of the check.
+func CheckIntegrity(repoName, repoPath string, dangling map[string]bool) (lfs.FsckResult, error) {
+	scanned, err := lfs.ScanPointers(repoPath, nil)
+	if err != nil {
+		return lfs.FsckResult{}, fmt.Errorf("❌ Failed to scan LFS pointers of '%s': %w", repoName, err)
+	}
+
+	var pointers []lfs.Pointer
+	for _, p := range scanned {
+		if !dangling[p.OID] {
+			pointers = append(pointers, p)
+		}
+	}
+
+	store, err := lfs.NewLocalStore(repoPath)
+	if err != nil {
+		return lfs.FsckResult{}, err
+	}
+
+	result := lfs.Fsck(store, pointers, true)
+	if result.OK() {
+		pterm.Success.Printf("Integrity check passed for '%s': %d objects\n", repoName, result.Checked)
+		return result, nil
+	}
+
+	for _, p := range result.Corrupt {
+		pterm.Error.Printf("Corrupt LFS object in '%s' removed: %s %s\n", repoName, p.OID, p.Path)
+	}
+	for _, p := range result.Missing {
+		pterm.Error.Printf("Missing LFS object in '%s': %s %s\n", repoName, p.OID, p.Path)
+	}
+
+	return result, fmt.Errorf("❌ Integrity check failed for '%s': %d corrupt, %d missing of %d objects, re-run pull to download them again",
+		repoName, len(result.Corrupt), len(result.Missing), result.Checked)
+}
+
+// integrityReport collects integrity results of every pulled repository
+type integrityReport struct {
+	mu      sync.Mutex
+	results map[string]lfs.FsckResult
+}
+
+func newIntegrityReport() *integrityReport {
+	return &integrityReport{results: make(map[string]lfs.FsckResult)}
+}
+
+func (r *integrityReport) record(repoName string, result lfs.FsckResult) {
+	r.mu.Lock()
+	r.results[repoName] = result
+	r.mu.Unlock()
+}
+
+// print renders a per-repository table of the integrity pass
+func (r *integrityReport) print() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.results) == 0 {
+		return
+	}
+
+	repos := make([]string, 0, len(r.results))
+	for repo := range r.results {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	data := pterm.TableData{{"Repository", "Objects", "Corrupt", "Missing", "Status"}}
+	for _, repo := range repos {
+		result := r.results[repo]
+		status := "✅ ok"
+		if !result.OK() {
+			status = "❌ incomplete"
+		}
+		data = append(data, []string{
+			repo,
+			strconv.Itoa(result.Checked),
+			strconv.Itoa(len(result.Corrupt)),
+			strconv.Itoa(len(result.Missing)),
+			status,
+		})
+	}
+
+	common.Printf("\n🔒 LFS integrity check:\n")
+	pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
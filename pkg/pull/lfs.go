@@ -0,0 +1,90 @@
+package pull
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/pterm/pterm"
+)
+
+// Result is the outcome of pulling one repository
+type Result struct {
+	Transfer common.TransferStats
+	// Missing are pointers whose objects the source doesn't have
+	Missing []lfs.Pointer
+}
+
+// downloadLFSObjects downloads the objects referenced by every ref of a
+// clone through the source's Batch API. Objects the source doesn't have are
+// collected in the result instead of failing the repository.
+func downloadLFSObjects(repoName, repoPath, cloneURL, token string) (Result, error) {
+	var result Result
+
+	pointers, err := lfs.ScanPointers(repoPath, nil)
+	if err != nil {
+		return result, fmt.Errorf("❌ Failed to scan LFS pointers: %w", err)
+	}
+	if len(pointers) == 0 {
+		pterm.Info.Printf("No LFS objects referenced in '%s'\n", repoName)
+		return result, nil
+	}
+
+	store, err := lfs.NewLocalStore(repoPath)
+	if err != nil {
+		return result, err
+	}
+
+	pterm.Info.Printf("Downloading %d LFS objects for repository '%s'...\n", len(pointers), repoName)
+	activity := common.RepoProgress(repoName)
+	activity.Objects("downloading LFS objects", len(lfs.UniquePointers(pointers)))
+	client := lfs.NewClient(lfs.EndpointForRepo(cloneURL), token)
+	client.OnRead = activity.Read
+	results := client.DownloadObjects(store, pointers, activity.Object)
+
+	var failures []string
+	for _, r := range results {
+		switch r.Status {
+		case lfs.StatusTransferred:
+			result.Transfer.ObjectsTransferred++
+			result.Transfer.BytesTransferred += r.Pointer.Size
+		case lfs.StatusSkipped:
+			result.Transfer.ObjectsSkipped++
+			result.Transfer.BytesSkipped += r.Pointer.Size
+		case lfs.StatusMissing:
+			result.Missing = append(result.Missing, r.Pointer)
+		default:
+			failures = append(failures, r.Failure())
+		}
+	}
+
+	log := common.RepoLogger(repoName)
+	log.Printf("LFS download: %d transferred (%s), %d already local (%s), %d missing on the source, %d failed",
+		result.Transfer.ObjectsTransferred, common.FormatBytes(result.Transfer.BytesTransferred),
+		result.Transfer.ObjectsSkipped, common.FormatBytes(result.Transfer.BytesSkipped),
+		len(result.Missing), len(failures))
+	for _, failure := range failures {
+		log.Printf("LFS download failed: %s", failure)
+	}
+
+	if len(failures) > 0 {
+		return result, fmt.Errorf("❌ Failed to pull %d LFS objects: %s", len(failures), lfs.SummarizeFailures(failures))
+	}
+
+	checkoutLFSFiles(repoName, repoPath)
+	return result, nil
+}
+
+// checkoutLFSFiles replaces pointer files in the working tree with their
+// downloaded contents. The objects are already stored, so a failure here
+// doesn't affect syncing and is only reported.
+func checkoutLFSFiles(repoName, repoPath string) {
+	common.RepoProgress(repoName).Step("checking out LFS files")
+	cmd := exec.Command("git", "lfs", "checkout")
+	cmd.Dir = repoPath
+	if output, err := common.RepoLogger(repoName).Run(cmd); err != nil {
+		pterm.Warning.Printf("Failed to check out LFS files of '%s': %s, %v\n", repoName, strings.TrimSpace(common.Redact(string(output))), err)
+	}
+}
@@ -0,0 +1,92 @@
+package pull
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+)
+
+// MissingObjectsFile is the name of the missing objects report in the work dir
+const MissingObjectsFile = "missing_objects.csv"
+
+// missingObjects collects pointers whose objects were never uploaded to the
+// source, per repository
+type missingObjects struct {
+	mu      sync.Mutex
+	objects map[string][]lfs.Pointer
+}
+
+func newMissingObjects() *missingObjects {
+	return &missingObjects{objects: make(map[string][]lfs.Pointer)}
+}
+
+func (m *missingObjects) record(repoName string, pointers []lfs.Pointer) {
+	if len(pointers) == 0 {
+		return
+	}
+	m.mu.Lock()
+	m.objects[repoName] = pointers
+	m.mu.Unlock()
+}
+
+// oids returns the missing object IDs of a repository
+func (m *missingObjects) oids(repoName string) map[string]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oids := make(map[string]bool)
+	for _, p := range m.objects[repoName] {
+		oids[p.OID] = true
+	}
+	return oids
+}
+
+func (m *missingObjects) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, pointers := range m.objects {
+		count += len(pointers)
+	}
+	return count
+}
+
+// write saves one row per missing object with the path and ref it was found in
+func (m *missingObjects) write(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating missing objects report: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"Repository", "OID", "Size", "Path", "Ref"}); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	repos := make([]string, 0, len(m.objects))
+	for repo := range m.objects {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	for _, repo := range repos {
+		for _, p := range m.objects[repo] {
+			if err := writer.Write([]string{repo, p.OID, strconv.FormatInt(p.Size, 10), p.Path, p.Ref}); err != nil {
+				return fmt.Errorf("error writing missing objects report: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
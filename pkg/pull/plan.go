@@ -0,0 +1,77 @@
+package pull
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/pterm/pterm"
+)
+
+// planPull prints the clones, updates and LFS downloads a pull would run,
+// without touching the work dir
+func planPull(records []common.InventoryRecord, workDir, sharedStore, hostname, diskCheck string, minFree int64) error {
+	plan := common.NewPlan("pull", len(records))
+	for _, record := range records {
+		plan.Repo(record.Repository)
+
+		cloneURL, err := ResolveCloneURL(record.CloneURL, record.SourceHost, hostname)
+		if err != nil {
+			plan.Problem("invalid clone URL: %v", err)
+			continue
+		}
+
+		repoPath := filepath.Join(workDir, record.Repository)
+		if _, err := os.Stat(repoPath); err != nil {
+			plan.Step("git clone %s into %s (about %s on disk)", cloneURL, repoPath, common.FormatBytes(record.EstimatedFootprint()))
+			if sharedStore != "" {
+				plan.Step("git config lfs.storage %s", sharedStore)
+			}
+			plan.Step("download the LFS objects of all refs (%s)", common.LFSEstimate(record))
+			continue
+		}
+
+		plan.Step("git pull --all in %s", repoPath)
+		if sharedStore != "" {
+			plan.Step("git config lfs.storage %s", sharedStore)
+		}
+		planMissingObjects(plan, repoPath)
+	}
+
+	// The disk check only reads free space, a shortfall is reported instead
+	// of stopping the plan
+	if err := checkDiskSpace(records, workDir, sharedStore, diskCheck, minFree); err != nil {
+		pterm.Warning.Printf("The pull would stop: %v\n", err)
+	}
+
+	return plan.Finish()
+}
+
+// planMissingObjects lists the LFS objects of an existing clone's current
+// refs that aren't stored locally yet. Objects of commits the update brings
+// in can only be counted after it.
+func planMissingObjects(plan *common.Plan, repoPath string) {
+	pointers, err := lfs.ScanPointers(repoPath, nil)
+	if err != nil {
+		plan.Note("can't scan LFS pointers: %v", err)
+		return
+	}
+	store, err := lfs.NewLocalStore(repoPath)
+	if err != nil {
+		plan.Note("%v", err)
+		return
+	}
+
+	var missing, present int
+	var missingBytes int64
+	for _, p := range pointers {
+		if store.Has(p) {
+			present++
+			continue
+		}
+		missing++
+		missingBytes += p.Size
+	}
+	plan.Step("download %d LFS objects (%s), %d already local, plus any objects of new commits", missing, common.FormatBytes(missingBytes), present)
+}
@@ -1,149 +1,256 @@
 package pull
 
 import (
-	"encoding/csv"
+	"errors"
 	"fmt"
-	"io"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
 	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
 	"github.com/pterm/pterm"
 	"github.com/spf13/viper"
 )
 
 type pullJob struct {
-	name     string
-	cloneURL string
+	name       string
+	cloneURL   string
+	sourceHost string
+	record     common.InventoryRecord
 }
 
 func PullLFSFromCSV() error {
-	inputFile := viper.GetString("GHMLFS_FILE")
+	records, err := common.ReadInventory(viper.GetString("GHMLFS_FILE"))
+	if err != nil {
+		return err
+	}
+
+	return PullRecords(records)
+}
+
+// PullRecords clones the repositories of an inventory and downloads their
+// LFS objects
+func PullRecords(records []common.InventoryRecord) error {
 	token := viper.GetString("GHMLFS_SOURCE_TOKEN")
 	workDir := viper.GetString("GHMLFS_WORK_DIR")
 	maxWorkers := viper.GetInt("GHMLFS_WORKERS")
+	hostname := viper.GetString("GHMLFS_SOURCE_HOSTNAME")
+	sharedStore := viper.GetString("GHMLFS_SHARED_STORE")
+	diskCheck := viper.GetString("GHMLFS_DISK_CHECK")
+	minFreeSpace := viper.GetString("GHMLFS_MIN_FREE_SPACE")
+
+	if diskCheck == "" {
+		diskCheck = DiskCheckRefuse
+	}
+	if diskCheck != DiskCheckRefuse && diskCheck != DiskCheckWarn && diskCheck != DiskCheckOff {
+		return fmt.Errorf("invalid disk check %q, must be %s, %s or %s", diskCheck, DiskCheckRefuse, DiskCheckWarn, DiskCheckOff)
+	}
+
+	var minFree int64
+	if minFreeSpace != "" {
+		parsed, err := common.ParseBytes(minFreeSpace)
+		if err != nil {
+			return fmt.Errorf("invalid minimum free space: %w", err)
+		}
+		minFree = parsed
+	}
+
+	if sharedStore != "" {
+		abs, err := filepath.Abs(sharedStore)
+		if err != nil {
+			return fmt.Errorf("invalid shared store path: %w", err)
+		}
+		sharedStore = abs
+	}
+	common.RegisterSecrets(token)
+	common.ConfigureRepoLogs(workDir)
+	usage := newStoreUsage()
+	integrity := newIntegrityReport()
+	missing := newMissingObjects()
 
-	// Read CSV file
-	file, err := os.Open(inputFile)
+	store, mode, err := state.OpenForRun(workDir)
 	if err != nil {
-		return fmt.Errorf("error opening input file: %w", err)
+		return err
 	}
-	defer file.Close()
+	records = store.Select(records, state.PhasePulled, mode)
 
-	reader := csv.NewReader(file)
-	// Skip header
-	if _, err := reader.Read(); err != nil {
-		return fmt.Errorf("error reading CSV header: %w", err)
+	if viper.GetBool("GHMLFS_DRY_RUN") {
+		return planPull(records, workDir, sharedStore, hostname, diskCheck, minFree)
 	}
 
-	// Create jobs channel and track unique repositories
+	if err := checkDiskSpace(records, workDir, sharedStore, diskCheck, minFree); err != nil {
+		return err
+	}
+
+	// Create jobs channel
 	jobs := make(chan pullJob)
-	seen := make(map[string]bool)
 
 	// Start goroutine to send jobs
 	go func() {
 		defer close(jobs)
-		for {
-			record, err := reader.Read()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				fmt.Printf("Error reading CSV record: %v\n", err)
-				continue
-			}
-			if len(record) != 3 {
-				fmt.Printf("Invalid CSV record format, expected 3 columns got %d\n", len(record))
-				continue
-			}
-
-			repoName := record[0]
-			if seen[repoName] {
-				continue
-			}
-			seen[repoName] = true
-
+		for _, record := range records {
 			jobs <- pullJob{
-				name:     repoName,
-				cloneURL: record[2], // Store raw URL
+				name:       record.Repository,
+				cloneURL:   record.CloneURL, // Store raw URL
+				sourceHost: record.SourceHost,
+				record:     record,
 			}
 		}
 	}()
 
 	// Create and run worker pool
 	stats := common.NewProcessStats()
-	err = common.WorkerPool(jobs, maxWorkers, stats, func(job pullJob) error {
-		// Authenticate URL here, in the worker
-		urlParts := strings.SplitN(job.cloneURL, "://", 2)
-		if len(urlParts) != 2 {
-			return fmt.Errorf("invalid clone URL format for %s", job.name)
+	stats.Expect(records)
+	process := func(job pullJob) error {
+		waitForSpace(job.name, workDir, minFree)
+		if sharedStore != "" {
+			waitForSpace(job.name, sharedStore, minFree)
+		}
+
+		cloneURL, err := ResolveCloneURL(job.cloneURL, job.sourceHost, hostname)
+		if err != nil {
+			return fmt.Errorf("❌ Invalid clone URL for %s: %w", job.name, err)
+		}
+
+		result, pullErr := PullLFSContent(job.name, cloneURL, token, workDir, sharedStore)
+		stats.RecordTransfer(job.name, result.Transfer)
+		missing.record(job.name, result.Missing)
+		var partial *common.PartialError
+		if pullErr != nil && !errors.As(pullErr, &partial) {
+			return pullErr
+		}
+
+		fsck, err := CheckIntegrity(job.name, filepath.Join(workDir, job.name), missing.oids(job.name))
+		integrity.record(job.name, fsck)
+		if err != nil {
+			return err
 		}
-		authenticatedURL := fmt.Sprintf("%s://%s@%s", urlParts[0], token, urlParts[1])
 
-		return PullLFSContent(job.name, authenticatedURL, token, workDir)
+		if sharedStore != "" {
+			if err := usage.record(job.name, filepath.Join(workDir, job.name)); err != nil {
+				pterm.Warning.Printf("Failed to scan LFS objects of '%s' for the store summary: %v\n", job.name, err)
+			}
+		}
+		return pullErr
+	}
+	err = common.WorkerPool(jobs, maxWorkers, stats, func(job pullJob) error {
+		return stats.Track(job.record, state.PhasePulled, func() error {
+			return store.Track(job.name, state.PhasePulled, func() error {
+				return process(job)
+			})
+		})
 	})
 
 	// Print summary
+	integrity.print()
 	stats.PrintSummary(workDir)
+	if resultsErr := stats.WriteResults(workDir, "pull"); resultsErr != nil {
+		return resultsErr
+	}
+	if sharedStore != "" {
+		usage.printSummary(sharedStore)
+	}
+
+	if count := missing.count(); count > 0 {
+		reportPath := filepath.Join(workDir, MissingObjectsFile)
+		if err := missing.write(reportPath); err != nil {
+			return err
+		}
+		pterm.Warning.Printf("%d LFS objects are missing on the source, see %s\n", count, reportPath)
+	}
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("\n✅ Pull completed successfully!")
+	common.Println("\n✅ Pull completed successfully!")
 	return nil
 }
 
-// PullLFSContent remains unchanged from your original version
-func PullLFSContent(repoName, cloneURL, token, workDir string) error {
+// ResolveCloneURL validates an inventory clone URL. Older inventories don't
+// record the source host, so the configured hostname is used instead and URLs
+// for another server are still rejected.
+func ResolveCloneURL(cloneURL, sourceHost, hostname string) (string, error) {
+	if sourceHost == "" && hostname != "" {
+		if parsed, err := url.Parse(hostname); err == nil {
+			sourceHost = parsed.Host
+		}
+	}
+
+	parsed, err := common.ValidateCloneURL(cloneURL, sourceHost)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
+
+// PullLFSContent clones or updates a repository and downloads the LFS
+// objects of all its refs. When sharedStore is set the objects are kept there
+// instead of in the clone. Objects missing on the source are returned in the
+// result along with a *common.PartialError.
+func PullLFSContent(repoName, cloneURL, token, workDir, sharedStore string) (Result, error) {
 	repoPath := filepath.Join(workDir, repoName)
+	log := common.RepoLogger(repoName)
 
 	// Create working directory if it doesn't exist
 	if err := os.MkdirAll(workDir, 0755); err != nil {
-		return fmt.Errorf("❌ Failed to create working directory: %w", err)
+		return Result{}, fmt.Errorf("❌ Failed to create working directory: %w", err)
 	}
 
 	// Check if the repository already exists
 	if _, err := os.Stat(repoPath); err == nil {
 		pterm.Info.Printf("Repository exists '%s', proceeding with update\n", repoName)
+		common.RepoProgress(repoName).Step("updating")
+
+		if err := configureSharedStore(repoPath, sharedStore); err != nil {
+			return Result{}, err
+		}
 
 		pullCmd := exec.Command("git", "pull", "--all")
 		pullCmd.Dir = repoPath
-		if output, err := pullCmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("❌ Failed to pull updates: %s, %w", string(output), err)
+		pullCmd.Env = append(append(os.Environ(), "GIT_LFS_SKIP_SMUDGE=1"), common.GitTraceEnv()...)
+		if output, err := log.Run(pullCmd); err != nil {
+			return Result{}, fmt.Errorf("❌ Failed to pull updates: %s, %w", common.Redact(string(output)), err)
 		}
+	} else {
+		// Authenticate URL here, only for the clone
+		authenticatedURL, err := url.Parse(cloneURL)
+		if err != nil {
+			return Result{}, fmt.Errorf("❌ Invalid clone URL: %w", err)
+		}
+		authenticatedURL.User = url.User(token)
 
-		lfsPullCmd := exec.Command("git", "lfs", "pull")
-		lfsPullCmd.Dir = repoPath
-		if output, err := lfsPullCmd.CombinedOutput(); err != nil {
-			return fmt.Errorf("❌ Failed to pull LFS content: %s, %w", string(output), err)
+		// Clone the repository with GIT_LFS_SKIP_SMUDGE to avoid large file download during clone
+		pterm.Info.Printf("Cloning repository '%s'...\n", repoName)
+		common.RepoProgress(repoName).Step("cloning")
+		cloneCmd := exec.Command("git", "clone", authenticatedURL.String())
+		cloneCmd.Dir = workDir
+		cloneCmd.Env = append(append(os.Environ(), "GIT_LFS_SKIP_SMUDGE=1"), common.GitTraceEnv()...)
+		if output, err := log.Run(cloneCmd); err != nil {
+			return Result{}, fmt.Errorf("❌ Failed to clone repository: %s, %w", common.Redact(string(output)), err)
 		}
 
-		pterm.Success.Printf("Synchronization with '%s' completed successfully\n", repoName)
-		return nil
+		if err := configureSharedStore(repoPath, sharedStore); err != nil {
+			return Result{}, err
+		}
 	}
 
-	// Clone the repository with GIT_LFS_SKIP_SMUDGE to avoid large file download during clone
-	pterm.Info.Printf("Cloning repository '%s'...\n", repoName)
-	cloneCmd := exec.Command("git", "clone", cloneURL)
-	cloneCmd.Dir = workDir
-	cloneCmd.Env = append(os.Environ(), "GIT_LFS_SKIP_SMUDGE=1")
-	if output, err := cloneCmd.CombinedOutput(); err != nil {
-		errMsg := strings.ReplaceAll(string(output), token, "****")
-		return fmt.Errorf("❌ Failed to clone repository: %s, %w", errMsg, err)
+	result, err := downloadLFSObjects(repoName, repoPath, cloneURL, token)
+	if err != nil {
+		return result, err
 	}
 
-	pterm.Info.Printf("Pulling LFS objects for repository '%s'...\n", repoName)
-
-	// Pull LFS content using the environment token
-	lfsPullCmd := exec.Command("git", "lfs", "pull")
-	lfsPullCmd.Dir = repoPath
-	if output, err := lfsPullCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("❌ Failed to pull LFS content: %s, %w", string(output), err)
+	if len(result.Missing) > 0 {
+		for _, p := range result.Missing {
+			pterm.Warning.Printf("LFS object missing on the source for '%s': %s %s (%s)\n", repoName, p.OID, p.Path, p.Ref)
+		}
+		return result, &common.PartialError{
+			Err: fmt.Errorf("⚠️  %s: %d LFS objects are missing on the source", repoName, len(result.Missing)),
+		}
 	}
 
 	pterm.Success.Printf("synchronized: %s\n", repoName)
-	return nil
+	return result, nil
 }
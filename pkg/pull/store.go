@@ -0,0 +1,88 @@
+package pull
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+// configureSharedStore points a clone's LFS storage at a directory shared by
+// every clone, so an object referenced by several repositories is only
+// downloaded and stored once
+func configureSharedStore(repoPath, sharedStore string) error {
+	if sharedStore == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(sharedStore, 0755); err != nil {
+		return fmt.Errorf("❌ Failed to create shared LFS store: %w", err)
+	}
+
+	cmd := exec.Command("git", "config", "lfs.storage", sharedStore)
+	cmd.Dir = repoPath
+	if output, err := common.RepoLogger(filepath.Base(repoPath)).Run(cmd); err != nil {
+		return fmt.Errorf("❌ Failed to configure shared LFS store: %s, %w", common.Redact(string(output)), err)
+	}
+
+	return nil
+}
+
+// storeUsage collects the objects each repository references so the savings
+// of the shared store can be reported once all repositories are pulled
+type storeUsage struct {
+	mu      sync.Mutex
+	objects map[string]map[string]int64 // repository -> oid -> size
+}
+
+func newStoreUsage() *storeUsage {
+	return &storeUsage{objects: make(map[string]map[string]int64)}
+}
+
+// record scans a pulled repository for the objects it references
+func (u *storeUsage) record(repoName, repoPath string) error {
+	pointers, err := lfs.ScanPointers(repoPath, nil)
+	if err != nil {
+		return err
+	}
+
+	objects := make(map[string]int64, len(pointers))
+	for _, p := range pointers {
+		objects[p.OID] = p.Size
+	}
+
+	u.mu.Lock()
+	u.objects[repoName] = objects
+	u.mu.Unlock()
+	return nil
+}
+
+// printSummary reports the space used by the shared store against the space
+// the same objects would take with a copy per repository
+func (u *storeUsage) printSummary(sharedStore string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var referenced int64
+	unique := make(map[string]int64)
+	for _, objects := range u.objects {
+		for oid, size := range objects {
+			referenced += size
+			unique[oid] = size
+		}
+	}
+
+	var deduplicated int64
+	for _, size := range unique {
+		deduplicated += size
+	}
+
+	common.Printf("🗄️  Shared LFS store: %s\n", sharedStore)
+	common.Printf("   Disk usage: %s\n", common.FormatBytes(common.DirSize(filepath.Join(sharedStore, "objects"))))
+	common.Printf("   Objects referenced: %d unique, %s across %d repositories\n", len(unique), common.FormatBytes(referenced), len(u.objects))
+	common.Printf("   Saved by deduplication: %s\n", common.FormatBytes(referenced-deduplicated))
+}
@@ -0,0 +1,319 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+)
+
+// maxTimelineRepos limits the per-repository bars of the HTML timeline
+const maxTimelineRepos = 100
+
+// maxErrorLength shortens error messages in the reports
+const maxErrorLength = 300
+
+// RenderMarkdown returns the report as a Markdown summary
+func RenderMarkdown(r *Report) string {
+	var b strings.Builder
+	t := r.Totals
+
+	fmt.Fprintf(&b, "# LFS Migration Report\n\n")
+	fmt.Fprintf(&b, "Generated %s from %s.\n\n", r.GeneratedAt.Format(time.RFC1123), strings.Join(r.Sources, ", "))
+
+	fmt.Fprintf(&b, "## Totals\n\n")
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Repositories | %d |\n", t.Repositories)
+	fmt.Fprintf(&b, "| ✅ Succeeded | %d |\n", t.Succeeded)
+	fmt.Fprintf(&b, "| ⚠️ Partial | %d |\n", t.Partial)
+	fmt.Fprintf(&b, "| ❌ Failed | %d |\n", t.Failed)
+	if t.Running > 0 {
+		fmt.Fprintf(&b, "| ⏳ Running or interrupted | %d |\n", t.Running)
+	}
+	fmt.Fprintf(&b, "| Repository size | %s |\n", common.FormatBytes(t.SizeBytes))
+	fmt.Fprintf(&b, "| LFS objects uploaded | %d (%s) |\n", t.Objects, common.FormatBytes(t.Uploaded))
+	fmt.Fprintf(&b, "| Started | %s |\n", formatTime(r.Start))
+	fmt.Fprintf(&b, "| Finished | %s |\n", formatTime(r.End))
+	fmt.Fprintf(&b, "| Elapsed | %s |\n\n", formatDuration(r.End.Sub(r.Start)))
+
+	fmt.Fprintf(&b, "## Organizations\n\n")
+	fmt.Fprintf(&b, "| Organization | Repositories | Succeeded | Partial | Failed | Size | Uploaded |\n")
+	fmt.Fprintf(&b, "|---|---:|---:|---:|---:|---:|---:|\n")
+	for _, org := range r.Orgs {
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %d | %s | %s |\n", escapeMarkdown(org.Org), org.Repositories,
+			org.Succeeded, org.Partial, org.Failed, common.FormatBytes(org.SizeBytes), common.FormatBytes(org.Uploaded))
+	}
+	b.WriteString("\n")
+
+	if len(r.Largest) > 0 {
+		fmt.Fprintf(&b, "## Largest Repositories\n\n")
+		fmt.Fprintf(&b, "| Repository | Organization | Size | Uploaded | Status |\n")
+		fmt.Fprintf(&b, "|---|---|---:|---:|---|\n")
+		for _, repo := range r.Largest {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", escapeMarkdown(repo.Name), escapeMarkdown(repo.Org),
+				common.FormatBytes(repo.SizeBytes), common.FormatBytes(repo.Uploaded()), statusLabel(repo.Status()))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "## Failures by Cause\n\n")
+	if len(r.Failures) == 0 {
+		fmt.Fprintf(&b, "No failures.\n\n")
+	}
+	for _, group := range r.Failures {
+		fmt.Fprintf(&b, "### %s (%d)\n\n", group.Class, len(group.Failures))
+		fmt.Fprintf(&b, "| Repository | Phase | Status | Error |\n|---|---|---|---|\n")
+		for _, f := range group.Failures {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", escapeMarkdown(f.Repository), f.Phase,
+				statusLabel(f.Status), escapeMarkdown(shorten(f.Error)))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "## Timeline\n\n")
+	fmt.Fprintf(&b, "| Phase | Repositories | Succeeded | Failed | First start | Last finish | Elapsed | Average |\n")
+	fmt.Fprintf(&b, "|---|---:|---:|---:|---|---|---:|---:|\n")
+	for _, p := range r.Timeline {
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %s | %s | %s | %s |\n", p.Phase, p.Repositories, p.Succeeded, p.Failed,
+			formatTime(p.FirstStart), formatTime(p.LastFinish), formatDuration(p.Wall()), formatDuration(p.Average()))
+	}
+	b.WriteString("\n")
+
+	if len(r.Days) > 0 {
+		phases := r.phaseNames()
+		fmt.Fprintf(&b, "### Completed per Day\n\n")
+		fmt.Fprintf(&b, "| Date | %s |\n", strings.Join(phases, " | "))
+		fmt.Fprintf(&b, "|---|%s\n", strings.Repeat("---:|", len(phases)))
+		for _, day := range r.Days {
+			counts := make([]string, len(phases))
+			for i, phase := range phases {
+				counts[i] = fmt.Sprint(day.Finished[phase])
+			}
+			fmt.Fprintf(&b, "| %s | %s |\n", day.Date, strings.Join(counts, " | "))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// RenderHTML returns the report as a self-contained HTML page
+func RenderHTML(r *Report) string {
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, htmlData{Report: r, Bars: r.bars()}); err != nil {
+		// The template is fixed, an error here is a bug
+		panic(fmt.Sprintf("rendering HTML report: %v", err))
+	}
+	return b.String()
+}
+
+// bar is one phase of a repository on the HTML timeline, positioned in
+// percent of the whole run
+type bar struct {
+	Phase  string
+	Status string
+	Left   float64
+	Width  float64
+	Title  string
+}
+
+type timelineRow struct {
+	Repository string
+	Bars       []bar
+}
+
+type htmlData struct {
+	*Report
+	Bars []timelineRow
+}
+
+// bars lays out the phases of the first repositories of the run
+func (r *Report) bars() []timelineRow {
+	span := r.End.Sub(r.Start)
+	if span <= 0 {
+		return nil
+	}
+
+	var rows []timelineRow
+	for _, repo := range r.Repos {
+		if len(rows) == maxTimelineRepos {
+			break
+		}
+		row := timelineRow{Repository: repo.Name}
+		for _, p := range repo.OrderedPhases() {
+			if p.StartedAt.IsZero() {
+				continue
+			}
+			end := p.FinishedAt
+			if end.IsZero() {
+				end = r.End
+			}
+			width := 100 * float64(end.Sub(p.StartedAt)) / float64(span)
+			if width < 0.3 {
+				width = 0.3
+			}
+			left := 100 * float64(p.StartedAt.Sub(r.Start)) / float64(span)
+			if left+width > 100 {
+				left = 100 - width
+			}
+			row.Bars = append(row.Bars, bar{
+				Phase:  p.Name,
+				Status: p.Status,
+				Left:   left,
+				Width:  width,
+				Title:  fmt.Sprintf("%s %s: %s, %s", repo.Name, p.Name, p.Status, formatDuration(p.Duration())),
+			})
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// phaseNames returns the phases that appear in the timeline, in order
+func (r *Report) phaseNames() []string {
+	names := make([]string, len(r.Timeline))
+	for i, p := range r.Timeline {
+		names[i] = p.Phase
+	}
+	return names
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04:05 UTC")
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
+
+func statusLabel(status string) string {
+	switch status {
+	case state.StatusSucceeded:
+		return "✅ succeeded"
+	case state.StatusPartial:
+		return "⚠️ partial"
+	case state.StatusFailed:
+		return "❌ failed"
+	case state.StatusRunning:
+		return "⏳ running"
+	}
+	return status
+}
+
+func shorten(message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	if len(message) > maxErrorLength {
+		return message[:maxErrorLength] + "…"
+	}
+	return message
+}
+
+func escapeMarkdown(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes":    common.FormatBytes,
+	"time":     formatTime,
+	"duration": formatDuration,
+	"status":   statusLabel,
+	"shorten":  shorten,
+	"percent": func(value float64) template.CSS {
+		return template.CSS(fmt.Sprintf("%.2f%%", value))
+	},
+	"count": func(day Day, phase string) int {
+		return day.Finished[phase]
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>LFS Migration Report</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; margin: 2rem auto; max-width: 1100px; padding: 0 1rem; }
+h1 { border-bottom: 1px solid #d0d7de; padding-bottom: .3rem; }
+h2 { margin-top: 2rem; border-bottom: 1px solid #d0d7de; padding-bottom: .3rem; }
+table { border-collapse: collapse; width: 100%; margin: 1rem 0; font-size: 14px; }
+th, td { border: 1px solid #d0d7de; padding: 6px 10px; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+td.num { text-align: right; }
+.meta { color: #656d76; font-size: 13px; }
+.cards { display: flex; flex-wrap: wrap; gap: 12px; }
+.card { border: 1px solid #d0d7de; border-radius: 6px; padding: 12px 16px; min-width: 140px; }
+.card .value { font-size: 24px; font-weight: 600; }
+.card .label { color: #656d76; font-size: 13px; }
+.succeeded { color: #1a7f37; } .partial { color: #9a6700; } .failed { color: #cf222e; } .running { color: #0969da; }
+.error { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 12px; word-break: break-word; }
+.timeline td.track { position: relative; width: 75%; padding: 0; }
+.timeline .bar { position: absolute; top: 5px; height: 14px; border-radius: 3px; opacity: .85; }
+.bar.succeeded { background: #2da44e; } .bar.partial { background: #bf8700; } .bar.failed { background: #cf222e; } .bar.running { background: #0969da; }
+.legend span { margin-right: 1rem; }
+</style>
+</head>
+<body>
+<h1>LFS Migration Report</h1>
+<p class="meta">Generated {{time .GeneratedAt}} from {{range $i, $s := .Sources}}{{if $i}}, {{end}}<code>{{$s}}</code>{{end}}</p>
+
+<h2>Totals</h2>
+<div class="cards">
+<div class="card"><div class="value">{{.Totals.Repositories}}</div><div class="label">Repositories</div></div>
+<div class="card"><div class="value succeeded">{{.Totals.Succeeded}}</div><div class="label">Succeeded</div></div>
+<div class="card"><div class="value partial">{{.Totals.Partial}}</div><div class="label">Partial</div></div>
+<div class="card"><div class="value failed">{{.Totals.Failed}}</div><div class="label">Failed</div></div>
+{{if .Totals.Running}}<div class="card"><div class="value running">{{.Totals.Running}}</div><div class="label">Running or interrupted</div></div>{{end}}
+<div class="card"><div class="value">{{bytes .Totals.SizeBytes}}</div><div class="label">Repository size</div></div>
+<div class="card"><div class="value">{{bytes .Totals.Uploaded}}</div><div class="label">{{.Totals.Objects}} LFS objects uploaded</div></div>
+</div>
+<p class="meta">Started {{time .Start}}, finished {{time .End}}</p>
+
+<h2>Organizations</h2>
+<table>
+<tr><th>Organization</th><th>Repositories</th><th>Succeeded</th><th>Partial</th><th>Failed</th><th>Size</th><th>Uploaded</th></tr>
+{{range .Orgs}}<tr><td>{{.Org}}</td><td class="num">{{.Repositories}}</td><td class="num">{{.Succeeded}}</td><td class="num">{{.Partial}}</td><td class="num">{{.Failed}}</td><td class="num">{{bytes .SizeBytes}}</td><td class="num">{{bytes .Uploaded}}</td></tr>
+{{end}}</table>
+
+{{if .Largest}}<h2>Largest Repositories</h2>
+<table>
+<tr><th>Repository</th><th>Organization</th><th>Size</th><th>Uploaded</th><th>Status</th></tr>
+{{range .Largest}}<tr><td>{{.Name}}</td><td>{{.Org}}</td><td class="num">{{bytes .SizeBytes}}</td><td class="num">{{bytes .Uploaded}}</td><td class="{{.Status}}">{{status .Status}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Failures by Cause</h2>
+{{if not .Failures}}<p>No failures.</p>{{end}}
+{{range .Failures}}<h3>{{.Class}} ({{len .Failures}})</h3>
+<table>
+<tr><th>Repository</th><th>Phase</th><th>Status</th><th>Error</th></tr>
+{{range .Failures}}<tr><td>{{.Repository}}</td><td>{{.Phase}}</td><td class="{{.Status}}">{{status .Status}}</td><td class="error">{{shorten .Error}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Timeline</h2>
+<table>
+<tr><th>Phase</th><th>Repositories</th><th>Succeeded</th><th>Failed</th><th>First start</th><th>Last finish</th><th>Elapsed</th><th>Average</th></tr>
+{{range .Timeline}}<tr><td>{{.Phase}}</td><td class="num">{{.Repositories}}</td><td class="num">{{.Succeeded}}</td><td class="num">{{.Failed}}</td><td>{{time .FirstStart}}</td><td>{{time .LastFinish}}</td><td class="num">{{duration .Wall}}</td><td class="num">{{duration .Average}}</td></tr>
+{{end}}</table>
+
+{{if .Days}}<h3>Completed per Day</h3>
+<table>
+<tr><th>Date</th>{{range .Timeline}}<th>{{.Phase}}</th>{{end}}</tr>
+{{range $day := .Days}}<tr><td>{{$day.Date}}</td>{{range $.Timeline}}<td class="num">{{count $day .Phase}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+{{if .Bars}}<h3>Repositories</h3>
+<p class="legend meta"><span class="succeeded">■ succeeded</span><span class="partial">■ partial</span><span class="failed">■ failed</span><span class="running">■ running</span>{{if gt (len .Repos) (len .Bars)}} Showing the first {{len .Bars}} of {{len .Repos}} repositories.{{end}}</p>
+<table class="timeline">
+{{range .Bars}}<tr><td>{{.Repository}}</td><td class="track">{{range .Bars}}<div class="bar {{.Status}}" style="left: {{percent .Left}}; width: {{percent .Width}}" title="{{.Title}}"></div>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
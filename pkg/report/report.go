@@ -0,0 +1,503 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+	"github.com/spf13/viper"
+)
+
+// Default settings
+const (
+	DefaultOutput = "migration_report"
+	DefaultTop    = 10
+)
+
+// unknownOrg groups repositories whose organization can't be told, such as
+// repositories only found in the state file
+const unknownOrg = "(unknown)"
+
+// phaseOrder is the order phases are shown in
+var phaseOrder = []string{
+	state.PhaseExported,
+	state.PhasePulled,
+	state.PhaseSynced,
+	state.PhaseVerified,
+	state.PhaseCompared,
+}
+
+// Phase is the last outcome of one phase of a repository
+type Phase struct {
+	Name       string
+	Status     string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	ErrorClass string
+	Error      string
+	Transfer   common.TransferStats
+}
+
+// Duration returns how long the phase ran, 0 while it's still running
+func (p *Phase) Duration() time.Duration {
+	if p.FinishedAt.IsZero() {
+		return 0
+	}
+	return p.FinishedAt.Sub(p.StartedAt)
+}
+
+// Repo is the combined outcome of a repository across phases
+type Repo struct {
+	Name      string
+	Org       string
+	SizeBytes int64
+	Phases    map[string]*Phase
+}
+
+// Status returns the overall status of the repository: failed when any
+// phase failed, then running, partial and succeeded
+func (r *Repo) Status() string {
+	rank := map[string]int{
+		state.StatusFailed:    4,
+		state.StatusRunning:   3,
+		state.StatusPartial:   2,
+		state.StatusSucceeded: 1,
+	}
+
+	status := state.StatusSucceeded
+	for _, p := range r.Phases {
+		if rank[p.Status] > rank[status] {
+			status = p.Status
+		}
+	}
+	return status
+}
+
+// Start returns the earliest start of any phase
+func (r *Repo) Start() time.Time {
+	var start time.Time
+	for _, p := range r.Phases {
+		if start.IsZero() || (!p.StartedAt.IsZero() && p.StartedAt.Before(start)) {
+			start = p.StartedAt
+		}
+	}
+	return start
+}
+
+// Uploaded returns the LFS bytes pushed to the target
+func (r *Repo) Uploaded() int64 {
+	if p, ok := r.Phases[state.PhaseSynced]; ok {
+		return p.Transfer.BytesTransferred
+	}
+	return 0
+}
+
+// OrderedPhases returns the phases of the repository in pipeline order
+func (r *Repo) OrderedPhases() []*Phase {
+	var phases []*Phase
+	for _, name := range phaseOrder {
+		if p, ok := r.Phases[name]; ok {
+			phases = append(phases, p)
+		}
+	}
+	return phases
+}
+
+// Totals counts repositories by overall status
+type Totals struct {
+	Repositories int
+	Succeeded    int
+	Partial      int
+	Failed       int
+	Running      int
+	SizeBytes    int64
+	Uploaded     int64
+	Objects      int64
+}
+
+// OrgSummary is the breakdown of one source organization
+type OrgSummary struct {
+	Org string
+	Totals
+}
+
+// Failure is one failed or partial phase
+type Failure struct {
+	Repository string
+	Phase      string
+	Status     string
+	Error      string
+}
+
+// FailureGroup holds the failures of one error class
+type FailureGroup struct {
+	Class    string
+	Failures []Failure
+}
+
+// PhaseTimeline summarizes when a phase ran across all repositories
+type PhaseTimeline struct {
+	Phase        string
+	Repositories int
+	Succeeded    int
+	Failed       int
+	FirstStart   time.Time
+	LastFinish   time.Time
+	TotalTime    time.Duration
+
+	finished int
+}
+
+// Wall returns the time from the first start to the last finish
+func (t PhaseTimeline) Wall() time.Duration {
+	if t.FirstStart.IsZero() || t.LastFinish.IsZero() {
+		return 0
+	}
+	return t.LastFinish.Sub(t.FirstStart)
+}
+
+// Average returns the mean duration of the phase per repository that
+// finished it
+func (t PhaseTimeline) Average() time.Duration {
+	if t.finished == 0 {
+		return 0
+	}
+	return t.TotalTime / time.Duration(t.finished)
+}
+
+// Day counts phases finished on one day
+type Day struct {
+	Date     string
+	Finished map[string]int
+}
+
+// Report is everything shown in the migration report
+type Report struct {
+	GeneratedAt time.Time
+	Sources     []string
+	Start       time.Time
+	End         time.Time
+	Totals      Totals
+	Orgs        []OrgSummary
+	Largest     []*Repo
+	Failures    []FailureGroup
+	Timeline    []PhaseTimeline
+	Days        []Day
+	Repos       []*Repo
+}
+
+// GenerateReport reads the migration state of the work dir and any results
+// reports, and writes an HTML page and a Markdown summary
+func GenerateReport() error {
+	workDir := viper.GetString("GHMLFS_WORK_DIR")
+	output := viper.GetString("GHMLFS_REPORT_OUTPUT")
+	top := viper.GetInt("GHMLFS_REPORT_TOP")
+
+	var resultFiles []string
+	for _, file := range strings.Split(viper.GetString("GHMLFS_RESULTS"), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			resultFiles = append(resultFiles, file)
+		}
+	}
+
+	if workDir == "" && len(resultFiles) == 0 {
+		return fmt.Errorf("a work dir or results files are required")
+	}
+	if output == "" {
+		output = DefaultOutput
+	}
+	if top <= 0 {
+		top = DefaultTop
+	}
+
+	report, err := Load(workDir, resultFiles, top)
+	if err != nil {
+		return err
+	}
+	if len(report.Repos) == 0 {
+		return fmt.Errorf("no repositories found in %s", strings.Join(report.Sources, ", "))
+	}
+
+	htmlPath := output + ".html"
+	markdownPath := output + ".md"
+	if viper.GetBool("GHMLFS_DRY_RUN") {
+		common.Printf("\n🧪 Dry run: read %d repositories from %s\n", len(report.Repos), strings.Join(report.Sources, ", "))
+		common.Printf("📄 Would write %s and %s\n", htmlPath, markdownPath)
+		return nil
+	}
+
+	if err := os.WriteFile(htmlPath, []byte(common.Redact(RenderHTML(report))), 0644); err != nil {
+		return fmt.Errorf("error writing HTML report: %w", err)
+	}
+	markdown := RenderMarkdown(report)
+	if err := os.WriteFile(markdownPath, []byte(common.Redact(markdown)), 0644); err != nil {
+		return fmt.Errorf("error writing Markdown report: %w", err)
+	}
+	if err := common.AppendStepSummary(markdown); err != nil {
+		return err
+	}
+
+	t := report.Totals
+	common.Printf("\n📊 Report Summary:\n")
+	common.Printf("Repositories: %d\n", t.Repositories)
+	common.Printf("✅ Succeeded: %d\n", t.Succeeded)
+	if t.Partial > 0 {
+		common.Printf("⚠️  Partial: %d\n", t.Partial)
+	}
+	common.Printf("❌ Failed: %d\n", t.Failed)
+	if t.Running > 0 {
+		common.Printf("⏳ Running or interrupted: %d\n", t.Running)
+	}
+	common.Printf("📄 HTML report: %s\n", htmlPath)
+	common.Printf("📄 Markdown report: %s\n", markdownPath)
+	return nil
+}
+
+// Load builds a report from the state file of workDir, the results reports
+// in workDir and resultFiles. Results carry the inventory and transfer counts,
+// the state file fills in phases no results report covers.
+func Load(workDir string, resultFiles []string, top int) (*Report, error) {
+	report := &Report{GeneratedAt: time.Now().UTC()}
+	repos := make(map[string]*Repo)
+
+	repo := func(name string) *Repo {
+		r, ok := repos[name]
+		if !ok {
+			r = &Repo{Name: name, Org: unknownOrg, Phases: make(map[string]*Phase)}
+			repos[name] = r
+		}
+		return r
+	}
+
+	if workDir != "" {
+		store, err := state.Open(workDir)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(store.Path()); err == nil {
+			report.Sources = append(report.Sources, store.Path())
+		}
+		for _, name := range store.Repos() {
+			for _, phase := range phaseOrder {
+				p, ok := store.Get(name, phase)
+				if !ok {
+					continue
+				}
+				repo(name).Phases[phase] = &Phase{
+					Name:       phase,
+					Status:     p.Status,
+					StartedAt:  p.StartedAt,
+					FinishedAt: p.FinishedAt,
+					ErrorClass: p.ErrorClass,
+					Error:      p.LastError,
+				}
+			}
+		}
+
+		found, err := filepath.Glob(filepath.Join(workDir, "*_results.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		resultFiles = append(found, resultFiles...)
+	}
+
+	for _, file := range resultFiles {
+		results, err := common.ReadResults(file)
+		if err != nil {
+			return nil, err
+		}
+		report.Sources = append(report.Sources, file)
+
+		for _, result := range results {
+			r := repo(result.Record.Repository)
+			if owner := result.Record.SourceOwner(); owner != "" {
+				r.Org = owner
+			}
+			if size := result.Record.RepositorySizeKB*1024 + result.Record.LFSSizeBytes; size > r.SizeBytes {
+				r.SizeBytes = size
+			}
+
+			// Keep the latest run of a phase, the state file and several
+			// results reports can describe the same one
+			existing, ok := r.Phases[result.Phase]
+			if ok && result.StartedAt.Before(existing.StartedAt.Add(-time.Second)) {
+				continue
+			}
+			r.Phases[result.Phase] = &Phase{
+				Name:       result.Phase,
+				Status:     result.Status,
+				StartedAt:  result.StartedAt,
+				FinishedAt: result.StartedAt.Add(result.Duration),
+				ErrorClass: result.ErrorClass,
+				Error:      result.Error,
+				Transfer:   result.Transfer,
+			}
+		}
+	}
+
+	for _, r := range repos {
+		report.Repos = append(report.Repos, r)
+	}
+	sort.Slice(report.Repos, func(i, j int) bool {
+		a, b := report.Repos[i], report.Repos[j]
+		if !a.Start().Equal(b.Start()) {
+			return a.Start().Before(b.Start())
+		}
+		return a.Name < b.Name
+	})
+
+	report.summarize(top)
+	return report, nil
+}
+
+func (t *Totals) add(r *Repo) {
+	t.Repositories++
+	t.SizeBytes += r.SizeBytes
+	t.Uploaded += r.Uploaded()
+	if p, ok := r.Phases[state.PhaseSynced]; ok {
+		t.Objects += p.Transfer.ObjectsTransferred
+	}
+
+	switch r.Status() {
+	case state.StatusSucceeded:
+		t.Succeeded++
+	case state.StatusPartial:
+		t.Partial++
+	case state.StatusFailed:
+		t.Failed++
+	case state.StatusRunning:
+		t.Running++
+	}
+}
+
+// summarize fills in the totals, breakdowns and timelines from the repos
+func (report *Report) summarize(top int) {
+	orgs := make(map[string]*OrgSummary)
+	groups := make(map[string]*FailureGroup)
+	phases := make(map[string]*PhaseTimeline)
+	days := make(map[string]*Day)
+
+	for _, r := range report.Repos {
+		report.Totals.add(r)
+
+		org, ok := orgs[r.Org]
+		if !ok {
+			org = &OrgSummary{Org: r.Org}
+			orgs[r.Org] = org
+		}
+		org.add(r)
+
+		for _, p := range r.OrderedPhases() {
+			if !p.StartedAt.IsZero() && (report.Start.IsZero() || p.StartedAt.Before(report.Start)) {
+				report.Start = p.StartedAt
+			}
+			if p.FinishedAt.After(report.End) {
+				report.End = p.FinishedAt
+			}
+			if p.StartedAt.After(report.End) {
+				report.End = p.StartedAt
+			}
+
+			t, ok := phases[p.Name]
+			if !ok {
+				t = &PhaseTimeline{Phase: p.Name}
+				phases[p.Name] = t
+			}
+			t.Repositories++
+			if !p.FinishedAt.IsZero() {
+				t.finished++
+				t.TotalTime += p.Duration()
+			}
+			if t.FirstStart.IsZero() || p.StartedAt.Before(t.FirstStart) {
+				t.FirstStart = p.StartedAt
+			}
+			if p.FinishedAt.After(t.LastFinish) {
+				t.LastFinish = p.FinishedAt
+			}
+
+			switch p.Status {
+			case state.StatusSucceeded, state.StatusPartial:
+				t.Succeeded++
+				date := p.FinishedAt.Format("2006-01-02")
+				day, ok := days[date]
+				if !ok {
+					day = &Day{Date: date, Finished: make(map[string]int)}
+					days[date] = day
+				}
+				day.Finished[p.Name]++
+			case state.StatusFailed:
+				t.Failed++
+			}
+
+			if p.Status == state.StatusFailed || p.Status == state.StatusPartial {
+				class := p.ErrorClass
+				if class == "" {
+					class = common.ErrorClassOther
+				}
+				group, ok := groups[class]
+				if !ok {
+					group = &FailureGroup{Class: class}
+					groups[class] = group
+				}
+				group.Failures = append(group.Failures, Failure{
+					Repository: r.Name,
+					Phase:      p.Name,
+					Status:     p.Status,
+					Error:      p.Error,
+				})
+			}
+		}
+	}
+
+	for _, org := range orgs {
+		report.Orgs = append(report.Orgs, *org)
+	}
+	sort.Slice(report.Orgs, func(i, j int) bool {
+		return report.Orgs[i].Org < report.Orgs[j].Org
+	})
+
+	for _, r := range report.Repos {
+		if r.SizeBytes > 0 {
+			report.Largest = append(report.Largest, r)
+		}
+	}
+	sort.SliceStable(report.Largest, func(i, j int) bool {
+		return report.Largest[i].SizeBytes > report.Largest[j].SizeBytes
+	})
+	if len(report.Largest) > top {
+		report.Largest = report.Largest[:top]
+	}
+
+	for _, group := range groups {
+		sort.Slice(group.Failures, func(i, j int) bool {
+			return group.Failures[i].Repository < group.Failures[j].Repository
+		})
+		report.Failures = append(report.Failures, *group)
+	}
+	sort.Slice(report.Failures, func(i, j int) bool {
+		a, b := report.Failures[i], report.Failures[j]
+		if len(a.Failures) != len(b.Failures) {
+			return len(a.Failures) > len(b.Failures)
+		}
+		return a.Class < b.Class
+	})
+
+	for _, name := range phaseOrder {
+		if t, ok := phases[name]; ok {
+			report.Timeline = append(report.Timeline, *t)
+		}
+	}
+
+	for _, day := range days {
+		report.Days = append(report.Days, *day)
+	}
+	sort.Slice(report.Days, func(i, j int) bool {
+		return report.Days[i].Date < report.Days[j].Date
+	})
+}
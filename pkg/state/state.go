@@ -0,0 +1,316 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/spf13/viper"
+)
+
+// FileName is the name of the state file in the work dir
+const FileName = "migration_state.json"
+
+// Phases a repository goes through
+const (
+	PhaseExported = "exported"
+	PhasePulled   = "pulled"
+	PhaseSynced   = "synced"
+	PhaseVerified = "verified"
+	PhaseCompared = "compared"
+)
+
+// Phase outcomes
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusPartial   = "partial"
+	StatusFailed    = "failed"
+)
+
+// Selection modes for reruns
+const (
+	SelectAll        = ""
+	SelectResume     = "resume"
+	SelectOnlyFailed = "only-failed"
+)
+
+// PhaseState is the last result of one phase for a repository
+type PhaseState struct {
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty"`
+}
+
+// RepoState holds the phases a repository has been through
+type RepoState struct {
+	Phases map[string]*PhaseState `json:"phases"`
+}
+
+// Store is the migration state of every repository, kept as a JSON file in
+// the work dir and rewritten whenever a phase changes. A nil store records
+// nothing, for commands run without a work dir.
+type Store struct {
+	path     string
+	readOnly bool
+	mu       sync.Mutex
+
+	Repositories map[string]*RepoState `json:"repositories"`
+}
+
+// Open loads the state file of a work dir, or starts an empty one. An empty
+// workDir returns a nil store.
+func Open(workDir string) (*Store, error) {
+	if workDir == "" {
+		return nil, nil
+	}
+
+	s := &Store{
+		path:         filepath.Join(workDir, FileName),
+		Repositories: make(map[string]*RepoState),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("error parsing state file %s: %w", s.path, err)
+	}
+	if s.Repositories == nil {
+		s.Repositories = make(map[string]*RepoState)
+	}
+	return s, nil
+}
+
+// Path returns the location of the state file
+func (s *Store) Path() string {
+	if s == nil {
+		return ""
+	}
+	return s.path
+}
+
+// Get returns the state of a phase for a repository
+func (s *Store) Get(repo, phase string) (PhaseState, bool) {
+	if s == nil {
+		return PhaseState{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.Repositories[repo]
+	if !ok {
+		return PhaseState{}, false
+	}
+	p, ok := r.Phases[phase]
+	if !ok {
+		return PhaseState{}, false
+	}
+	return *p, true
+}
+
+// Start marks a phase as running
+func (s *Store) Start(repo, phase string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.phase(repo, phase)
+	p.Status = StatusRunning
+	p.StartedAt = time.Now().UTC()
+	p.FinishedAt = time.Time{}
+	p.Attempts++
+	s.save()
+}
+
+// Finish records the outcome of a phase. A *common.PartialError is recorded
+// as partial, any other error as failed.
+func (s *Store) Finish(repo, phase string, err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.phase(repo, phase)
+	p.FinishedAt = time.Now().UTC()
+	p.LastError = ""
+	p.ErrorClass = common.ClassifyError(err)
+
+	var partial *common.PartialError
+	switch {
+	case errors.As(err, &partial):
+		p.Status = StatusPartial
+		p.LastError = common.Redact(err.Error())
+	case err != nil:
+		p.Status = StatusFailed
+		p.LastError = common.Redact(err.Error())
+	default:
+		p.Status = StatusSucceeded
+	}
+	s.save()
+}
+
+// Track runs fn as a phase of repo and records its outcome
+func (s *Store) Track(repo, phase string, fn func() error) error {
+	s.Start(repo, phase)
+	err := fn()
+	s.Finish(repo, phase, err)
+	return err
+}
+
+// Selected reports whether a repository should be processed for phase:
+// resume skips repositories that already succeeded, only-failed keeps
+// repositories whose phase failed, was partial or was interrupted
+func (s *Store) Selected(repo, phase, mode string) bool {
+	if mode == SelectAll {
+		return true
+	}
+
+	p, ok := s.Get(repo, phase)
+	switch mode {
+	case SelectResume:
+		return !ok || p.Status != StatusSucceeded
+	case SelectOnlyFailed:
+		return ok && p.Status != StatusSucceeded
+	}
+	return true
+}
+
+// Select returns the records to process for phase and prints how many were
+// skipped
+func (s *Store) Select(records []common.InventoryRecord, phase, mode string) []common.InventoryRecord {
+	if mode == SelectAll {
+		return records
+	}
+
+	var selected []common.InventoryRecord
+	for _, record := range records {
+		if s.Selected(record.Repository, phase, mode) {
+			selected = append(selected, record)
+		}
+	}
+
+	common.Infof("🔁 %s: %d of %d repositories selected for %s, %d skipped\n",
+		mode, len(selected), len(records), phase, len(records)-len(selected))
+	return selected
+}
+
+// Repos returns the names of all repositories in the store, sorted
+func (s *Store) Repos() []string {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repos := make([]string, 0, len(s.Repositories))
+	for repo := range s.Repositories {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+func (s *Store) phase(repo, phase string) *PhaseState {
+	r, ok := s.Repositories[repo]
+	if !ok {
+		r = &RepoState{Phases: make(map[string]*PhaseState)}
+		s.Repositories[repo] = r
+	}
+	p, ok := r.Phases[phase]
+	if !ok {
+		p = &PhaseState{}
+		r.Phases[phase] = p
+	}
+	return p
+}
+
+// save writes the state to a temporary file and renames it into place, so
+// an interrupted run never leaves a truncated state file. Failures are only
+// reported, the migration itself shouldn't stop because of them.
+func (s *Store) save() {
+	if s.readOnly {
+		return
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		common.Warnf("⚠️  Failed to encode migration state: %v\n", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		common.Warnf("⚠️  Failed to save migration state: %v\n", err)
+		return
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		common.Warnf("⚠️  Failed to save migration state: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		common.Warnf("⚠️  Failed to save migration state: %v\n", err)
+	}
+}
+
+// SelectionFromConfig returns the selection mode set with --resume or
+// --only-failed
+func SelectionFromConfig() (string, error) {
+	resume := viper.GetBool("GHMLFS_RESUME")
+	onlyFailed := viper.GetBool("GHMLFS_ONLY_FAILED")
+
+	switch {
+	case resume && onlyFailed:
+		return "", fmt.Errorf("--resume and --only-failed can't be used together")
+	case resume:
+		return SelectResume, nil
+	case onlyFailed:
+		return SelectOnlyFailed, nil
+	}
+	return SelectAll, nil
+}
+
+// OpenForRun loads the state of a work dir and the selection mode. Reruns
+// need a state file, so a mode without a work dir is an error. In a dry run
+// the store is read-only.
+func OpenForRun(workDir string) (*Store, string, error) {
+	mode, err := SelectionFromConfig()
+	if err != nil {
+		return nil, "", err
+	}
+
+	store, err := Open(workDir)
+	if err != nil {
+		return nil, "", err
+	}
+	if store == nil && mode != SelectAll {
+		return nil, "", fmt.Errorf("--%s needs a work dir to read the migration state from", mode)
+	}
+	// A dry run reads the state to select repositories but never records
+	if store != nil && viper.GetBool("GHMLFS_DRY_RUN") {
+		store.readOnly = true
+	}
+	return store, mode, nil
+}
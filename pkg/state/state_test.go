@@ -0,0 +1,120 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+// newTestStore returns a store in a temporary work dir where each repository
+// has the given status for PhaseSynced, "running" meaning it was interrupted
+func newTestStore(t *testing.T, statuses map[string]string) *Store {
+	t.Helper()
+	store, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for repo, status := range statuses {
+		store.Start(repo, PhaseSynced)
+		switch status {
+		case StatusSucceeded:
+			store.Finish(repo, PhaseSynced, nil)
+		case StatusPartial:
+			store.Finish(repo, PhaseSynced, &common.PartialError{Err: errors.New("1 object missing")})
+		case StatusFailed:
+			store.Finish(repo, PhaseSynced, errors.New("push failed"))
+		}
+	}
+	return store
+}
+
+func TestSelect(t *testing.T) {
+	store := newTestStore(t, map[string]string{
+		"done":        StatusSucceeded,
+		"partial":     StatusPartial,
+		"failed":      StatusFailed,
+		"interrupted": StatusRunning,
+	})
+	var records []common.InventoryRecord
+	for _, repo := range []string{"done", "partial", "failed", "interrupted", "new"} {
+		records = append(records, common.InventoryRecord{Repository: repo})
+	}
+
+	tests := []struct {
+		name  string
+		phase string
+		mode  string
+		want  []string
+	}{
+		{"all", PhaseSynced, SelectAll, []string{"done", "partial", "failed", "interrupted", "new"}},
+		{"resume", PhaseSynced, SelectResume, []string{"partial", "failed", "interrupted", "new"}},
+		{"only failed", PhaseSynced, SelectOnlyFailed, []string{"partial", "failed", "interrupted"}},
+		{"resume of a phase never run", PhaseVerified, SelectResume, []string{"done", "partial", "failed", "interrupted", "new"}},
+		{"only failed of a phase never run", PhaseVerified, SelectOnlyFailed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, record := range store.Select(records, tt.phase, tt.mode) {
+				got = append(got, record.Repository)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectWithoutStore(t *testing.T) {
+	var store *Store
+	records := []common.InventoryRecord{{Repository: "a"}, {Repository: "b"}}
+
+	if got := store.Select(records, PhaseSynced, SelectResume); len(got) != 2 {
+		t.Errorf("resume without state selected %d repositories, want all", len(got))
+	}
+	if got := store.Select(records, PhaseSynced, SelectOnlyFailed); len(got) != 0 {
+		t.Errorf("only-failed without state selected %d repositories, want none", len(got))
+	}
+}
+
+func TestTrackPersists(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		store.Track("api", PhasePulled, func() error { return fmt.Errorf("attempt %d failed", i+1) })
+	}
+	store.Track("web", PhasePulled, func() error { return nil })
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api, ok := reopened.Get("api", PhasePulled)
+	if !ok {
+		t.Fatal("api has no state after reopening")
+	}
+	if api.Status != StatusFailed || api.Attempts != 2 || api.LastError != "attempt 2 failed" {
+		t.Errorf("api state = %+v, want the second failed attempt", api)
+	}
+	if api.FinishedAt.Before(api.StartedAt) {
+		t.Errorf("api finished at %v before it started at %v", api.FinishedAt, api.StartedAt)
+	}
+
+	web, _ := reopened.Get("web", PhasePulled)
+	if web.Status != StatusSucceeded || web.LastError != "" {
+		t.Errorf("web state = %+v, want succeeded", web)
+	}
+
+	if got := reopened.Repos(); !reflect.DeepEqual(got, []string{"api", "web"}) {
+		t.Errorf("Repos() = %v", got)
+	}
+}
@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/verify"
+)
+
+// syncDirect copies the LFS objects of a repository from the source server
+// to the target without a clone. Pointers are read from every branch and tag
+// through the source API, and each object is streamed from the source's LFS
+// download action to the target's upload action. Git refs aren't pushed, the
+// target is expected to have them already.
+//
+// The scanned pointers and the objects missing on the source are returned
+// for the verification.
+func syncDirect(job syncJob, opts Options, spool *lfs.Spool) (common.TransferStats, *directScan, error) {
+	var stats common.TransferStats
+
+	sourceURL, err := common.ValidateCloneURL(job.record.CloneURL, job.record.SourceHost)
+	if err != nil {
+		return stats, nil, fmt.Errorf("❌ Invalid clone URL for %s: %w", job.repoName, err)
+	}
+	sourceOwner := job.record.SourceOwner()
+
+	common.Infof("Reading LFS pointers of %s/%s from the source API...\n", sourceOwner, job.repoName)
+	activity := common.RepoProgress(job.repoName)
+	activity.Step("reading LFS pointers")
+	pointers, err := lfs.ScanRemotePointers(sourceOwner, job.repoName, opts.SourceToken, opts.SourceHostname)
+	if err != nil {
+		return stats, nil, fmt.Errorf("failed to scan LFS pointers of %s: %w", job.repoName, err)
+	}
+	if len(pointers) == 0 {
+		common.Infof("No LFS objects referenced in %s\n", job.repoName)
+		return stats, &directScan{}, nil
+	}
+
+	objects := len(lfs.UniquePointers(pointers))
+	common.Infof("Streaming %d LFS objects from %s to %s...\n", objects, job.repoName, job.target)
+	activity.Objects("streaming LFS objects", objects)
+	source := lfs.NewClient(lfs.EndpointForRepo(sourceURL.String()), opts.SourceToken)
+	target := lfs.NewClient(lfs.EndpointForRepo(job.target.CloneURL(opts.Hostname)), opts.Token)
+	target.OnRead = activity.Read
+	results := target.CopyObjects(source, pointers, spool, activity.Object)
+
+	var failures []string
+	dangling := make(map[string]bool)
+	for _, result := range results {
+		switch result.Status {
+		case lfs.StatusTransferred:
+			stats.ObjectsTransferred++
+			stats.BytesTransferred += result.Pointer.Size
+		case lfs.StatusSkipped:
+			stats.ObjectsSkipped++
+			stats.BytesSkipped += result.Pointer.Size
+		case lfs.StatusMissing:
+			dangling[result.Pointer.OID] = true
+		default:
+			failures = append(failures, result.Failure())
+		}
+	}
+
+	common.Infof("LFS objects for %s: %d streamed (%s), %d already on target (%s)\n", job.repoName,
+		stats.ObjectsTransferred, common.FormatBytes(stats.BytesTransferred),
+		stats.ObjectsSkipped, common.FormatBytes(stats.BytesSkipped))
+	logTransfer(job.repoName, "stream", stats, failures)
+
+	if len(failures) > 0 {
+		return stats, nil, fmt.Errorf("failed to stream %d LFS objects: %s", len(failures), lfs.SummarizeFailures(failures))
+	}
+
+	if len(dangling) > 0 {
+		if !opts.AllowIncomplete {
+			return stats, nil, fmt.Errorf("%d LFS objects of %s are missing on the source, use --allow-incomplete to sync without them",
+				len(dangling), job.repoName)
+		}
+		common.Warnf("⚠️  Synced %s without %d LFS objects missing on the source\n", job.repoName, len(dangling))
+	}
+
+	return stats, &directScan{pointers: pointers, dangling: dangling}, nil
+}
+
+// directScan is what a direct sync found on the source
+type directScan struct {
+	pointers []lfs.Pointer
+	// dangling are objects the source doesn't have
+	dangling map[string]bool
+}
+
+// scanDirect reads the pointers of a repository that was synced in an
+// earlier run and asks the source which of their objects it doesn't have
+func scanDirect(job syncJob, opts Options) (*directScan, error) {
+	sourceURL, err := common.ValidateCloneURL(job.record.CloneURL, job.record.SourceHost)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Invalid clone URL for %s: %w", job.repoName, err)
+	}
+
+	common.RepoProgress(job.repoName).Step("reading LFS pointers")
+	pointers, err := lfs.ScanRemotePointers(job.record.SourceOwner(), job.repoName, opts.SourceToken, opts.SourceHostname)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan LFS pointers of %s: %w", job.repoName, err)
+	}
+	pointers = lfs.UniquePointers(pointers)
+	if len(pointers) == 0 {
+		return &directScan{}, nil
+	}
+
+	specs := make([]lfs.ObjectSpec, len(pointers))
+	for i, p := range pointers {
+		specs[i] = lfs.ObjectSpec{OID: p.OID, Size: p.Size}
+	}
+	source := lfs.NewClient(lfs.EndpointForRepo(sourceURL.String()), opts.SourceToken)
+	response, err := source.Batch(lfs.OperationDownload, specs)
+	if err != nil {
+		return nil, fmt.Errorf("source batch request for %s failed: %w", job.repoName, err)
+	}
+
+	dangling := make(map[string]bool)
+	for _, obj := range response.Objects {
+		if obj.Error != nil && (obj.Error.Code == http.StatusNotFound || obj.Error.Code == http.StatusGone) {
+			dangling[obj.OID] = true
+		}
+	}
+	return &directScan{pointers: pointers, dangling: dangling}, nil
+}
+
+// verifyDirect checks the scanned pointers against the target, leaving out
+// objects the source doesn't have. Without a scan from this run, the source
+// is scanned again.
+func verifyDirect(job syncJob, scan *directScan, opts Options) error {
+	if scan == nil {
+		var err error
+		if scan, err = scanDirect(job, opts); err != nil {
+			return err
+		}
+	}
+
+	var expected []lfs.Pointer
+	for _, p := range scan.pointers {
+		if !scan.dangling[p.OID] {
+			expected = append(expected, p)
+		}
+	}
+
+	common.Infof("Verifying LFS objects of %s on %s...\n", job.repoName, job.target)
+	common.RepoProgress(job.repoName).Step("verifying")
+	report, err := verify.VerifyPointers(job.repoName, expected, job.target, opts.Hostname, opts.Token)
+	if err != nil {
+		return err
+	}
+
+	if report.HasGaps() {
+		verify.PrintReports([]verify.Report{report})
+		return fmt.Errorf("verification of %s failed, %d missing and %d mismatched objects: %w",
+			job.target, len(report.Missing), len(report.SizeMismatch), verify.ErrGaps)
+	}
+
+	common.Infof("Verified %d LFS objects of %s on %s\n", report.Expected(), job.repoName, job.target)
+	return nil
+}
@@ -0,0 +1,165 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/verify"
+)
+
+// uploadLFSObjects uploads the LFS objects reachable from refs to the target
+// using the Batch API. The target answers which objects it already has, so
+// only missing objects are sent. An empty refs list means all local refs.
+//
+// Local objects are hashed first. Corrupt or missing objects stop the
+// upload unless opts.AllowIncomplete is set, in which case they are left out.
+func uploadLFSObjects(repoName, repoPath, remoteURL string, refs []string, opts Options) (common.TransferStats, error) {
+	var stats common.TransferStats
+
+	pointers, err := lfs.ScanPointers(repoPath, refs)
+	if err != nil {
+		return stats, fmt.Errorf("failed to scan LFS pointers: %w", err)
+	}
+	if len(pointers) == 0 {
+		common.Infof("No LFS objects referenced in %s\n", repoName)
+		return stats, nil
+	}
+
+	store, err := lfs.NewLocalStore(repoPath)
+	if err != nil {
+		return stats, err
+	}
+
+	pointers, err = checkLocalObjects(repoName, store, pointers, opts.AllowIncomplete)
+	if err != nil {
+		return stats, err
+	}
+
+	common.Infof("Uploading %d LFS objects for %s...\n", len(pointers), repoName)
+	activity := common.RepoProgress(repoName)
+	activity.Objects("uploading LFS objects", len(lfs.UniquePointers(pointers)))
+	client := lfs.NewClient(lfs.EndpointForRepo(remoteURL), opts.Token)
+	client.OnRead = activity.Read
+	results := client.UploadObjects(store, pointers, activity.Object)
+
+	var failures []string
+	for _, result := range results {
+		switch result.Status {
+		case lfs.StatusTransferred:
+			stats.ObjectsTransferred++
+			stats.BytesTransferred += result.Pointer.Size
+		case lfs.StatusSkipped:
+			stats.ObjectsSkipped++
+			stats.BytesSkipped += result.Pointer.Size
+		default:
+			failures = append(failures, result.Failure())
+		}
+	}
+
+	common.Infof("LFS objects for %s: %d uploaded (%s), %d already on target (%s)\n", repoName,
+		stats.ObjectsTransferred, common.FormatBytes(stats.BytesTransferred),
+		stats.ObjectsSkipped, common.FormatBytes(stats.BytesSkipped))
+	logTransfer(repoName, "upload", stats, failures)
+
+	if len(failures) > 0 {
+		return stats, fmt.Errorf("failed to push %d LFS objects: %s", len(failures), lfs.SummarizeFailures(failures))
+	}
+
+	return stats, nil
+}
+
+// checkLocalObjects runs an integrity pass over the objects about to be
+// uploaded. Without allowIncomplete any corrupt or missing object is an
+// error, with it those objects are dropped from the upload.
+func checkLocalObjects(repoName string, store *lfs.LocalStore, pointers []lfs.Pointer, allowIncomplete bool) ([]lfs.Pointer, error) {
+	common.RepoProgress(repoName).Step("checking local LFS objects")
+	result := lfs.Fsck(store, pointers, false)
+	if result.OK() {
+		return pointers, nil
+	}
+
+	if !allowIncomplete {
+		return nil, fmt.Errorf("refusing to push %s: %d corrupt and %d missing local LFS objects, re-run pull or use --allow-incomplete",
+			repoName, len(result.Corrupt), len(result.Missing))
+	}
+
+	common.Warnf("⚠️  Pushing %s without %d corrupt and %d missing local LFS objects\n",
+		repoName, len(result.Corrupt), len(result.Missing))
+
+	bad := result.Bad()
+	var complete []lfs.Pointer
+	for _, p := range pointers {
+		if !bad[p.OID] {
+			complete = append(complete, p)
+		}
+	}
+	return complete, nil
+}
+
+// verifyTarget checks that every LFS object of a synced repository is on the
+// target, listing the ones that aren't
+func verifyTarget(job syncJob, opts Options) error {
+	common.Infof("Verifying LFS objects of %s on %s...\n", job.repoName, job.target)
+	common.RepoProgress(job.repoName).Step("verifying")
+	repoPath := filepath.Join(job.workDir, job.repoName)
+	refs, err := SyncedRefs(job.repoName, repoPath, opts)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		common.Infof("No refs of %s were synced, nothing to verify\n", job.repoName)
+		return nil
+	}
+
+	report, err := verify.VerifyRepository(job.repoName, repoPath, refs, job.target, opts.Hostname, opts.Token)
+	if err != nil {
+		return err
+	}
+
+	if report.HasGaps() {
+		verify.PrintReports([]verify.Report{report})
+		return fmt.Errorf("verification of %s failed, %d missing and %d mismatched objects: %w",
+			job.target, len(report.Missing), len(report.SizeMismatch), verify.ErrGaps)
+	}
+
+	common.Infof("Verified %d LFS objects of %s on %s\n", report.Expected(), job.repoName, job.target)
+	return nil
+}
+
+// SyncedRefs returns the refs of the clone at repoPath whose LFS objects
+// sync uploads, so verification checks the same content: every branch that
+// isn't diverged on the target, or with --lfs-only all local refs or the
+// target's refs present in the clone. The clone's origin must already point
+// at the target.
+func SyncedRefs(repoName, repoPath string, opts Options) ([]string, error) {
+	env := gitEnv()
+	if opts.LFSOnly {
+		if opts.RefSource == RefSourceTarget {
+			return targetCommits(repoName, repoPath, env)
+		}
+		return lfs.ListRefs(repoPath)
+	}
+
+	localRefs, err := listLocalBranches(repoPath, env)
+	if err != nil {
+		return nil, err
+	}
+	remoteRefs, err := listRemoteRefs(repoPath, env)
+	if err != nil {
+		return nil, err
+	}
+	return uploadRefs(compareRefs(repoPath, localRefs, remoteRefs, env)), nil
+}
+
+// logTransfer adds the outcome of an LFS transfer to the repository log
+func logTransfer(repoName, operation string, stats common.TransferStats, failures []string) {
+	log := common.RepoLogger(repoName)
+	log.Printf("LFS %s: %d transferred (%s), %d already on target (%s), %d failed", operation,
+		stats.ObjectsTransferred, common.FormatBytes(stats.BytesTransferred),
+		stats.ObjectsSkipped, common.FormatBytes(stats.BytesSkipped), len(failures))
+	for _, failure := range failures {
+		log.Printf("LFS %s failed: %s", operation, failure)
+	}
+}
@@ -0,0 +1,244 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/api"
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+// planSync prints what a sync would create, push and upload for each job.
+// Targets and their refs are only read, and the target LFS server is asked
+// which objects it already has with a batch request, which stores nothing.
+func planSync(jobs []syncJob, opts Options, createMissing bool) error {
+	plan := common.NewPlan("sync", len(jobs))
+	for _, job := range jobs {
+		plan.Repo(fmt.Sprintf("%s → %s", job.repoName, job.target))
+
+		if job.verifyOnly {
+			plan.Step("already synced, verify every LFS object on %s", job.target)
+			continue
+		}
+
+		exists, ok := planTarget(plan, &job, opts, createMissing)
+		if !ok {
+			continue
+		}
+
+		if opts.Direct {
+			planDirect(plan, job, opts, exists)
+		} else {
+			planPush(plan, job, opts, exists)
+		}
+
+		if job.defaultBranch != "" {
+			plan.Step("set the default branch of %s to %s", job.target, job.defaultBranch)
+		}
+		if opts.Verify {
+			plan.Step("verify every LFS object on %s", job.target)
+		}
+	}
+	return plan.Finish()
+}
+
+// planTarget runs the preflight check of a job read-only. It reports
+// whether the target exists and whether the sync can go ahead.
+func planTarget(plan *common.Plan, job *syncJob, opts Options, createMissing bool) (bool, bool) {
+	repo, err := api.GetRepository(job.target.Owner, job.target.Name, opts.Token, opts.Hostname)
+	switch {
+	case err != nil:
+		plan.Problem("can't check target repository %s: %v", job.target, err)
+		return false, false
+	case repo == nil && createMissing:
+		visibility, defaultBranch := targetSettings(*job)
+		plan.Step("create %s repository %s", visibility, job.target)
+		job.defaultBranch = defaultBranch
+		return false, true
+	case repo == nil:
+		plan.Problem("target repository %s doesn't exist, use --create-missing to create it", job.target)
+		return false, false
+	case repo.GetArchived():
+		plan.Problem("target repository %s is archived and can't be pushed to", job.target)
+		return false, false
+	}
+	return true, true
+}
+
+// planPush plans the ref push and LFS upload of a local clone
+func planPush(plan *common.Plan, job syncJob, opts Options, exists bool) {
+	repoPath := filepath.Join(job.workDir, job.repoName)
+	if _, err := os.Stat(repoPath); err != nil {
+		plan.Note("no local clone at %s yet, refs and LFS objects can only be planned once it's pulled", repoPath)
+		return
+	}
+
+	remoteURL := job.target.CloneURL(opts.Hostname)
+	plan.Step("configure gh authentication and the global git credential helper")
+	plan.Step("git remote set-url origin %s", remoteURL)
+
+	remote := make(map[string]string)
+	if exists {
+		refs, err := api.ListRefCommits(job.target.Owner, job.target.Name, opts.Token, opts.Hostname)
+		if err != nil {
+			plan.Problem("can't list refs of %s: %v", job.target, err)
+			return
+		}
+		for _, ref := range refs {
+			remote[ref.Ref] = ref.SHA
+		}
+	}
+
+	if opts.LFSOnly && opts.RefSource == RefSourceTarget {
+		var commits []string
+		seen := make(map[string]bool)
+		for _, sha := range remote {
+			if !seen[sha] && commitExists(repoPath, sha, nil) {
+				seen[sha] = true
+				commits = append(commits, sha)
+			}
+		}
+		if len(commits) == 0 {
+			plan.Step("nothing to upload, no target refs match local commits")
+			return
+		}
+		planUpload(plan, job, repoPath, remoteURL, commits, opts, exists)
+		return
+	}
+
+	if opts.LFSOnly {
+		planUpload(plan, job, repoPath, remoteURL, nil, opts, exists)
+		return
+	}
+
+	local, err := listLocalBranches(repoPath, nil)
+	if err != nil {
+		plan.Problem("%v", err)
+		return
+	}
+	comparisons := compareRefs(repoPath, local, remote, nil)
+	for _, c := range comparisons {
+		if c.status == refDiverged {
+			plan.Note("skip diverged ref %s (local %s, target %s)", c.ref, shortSHA(c.local), shortSHA(c.remote))
+		}
+	}
+
+	upload := uploadRefs(comparisons)
+	if len(upload) == 0 {
+		plan.Step("nothing to sync, every branch diverged on the target")
+		return
+	}
+	planUpload(plan, job, repoPath, remoteURL, upload, opts, exists)
+
+	pushable := pushableRefs(comparisons)
+	if len(pushable) == 0 {
+		plan.Step("no refs to push, the target is up to date")
+		return
+	}
+
+	var refs []string
+	for _, ref := range pushable {
+		refs = append(refs, strings.TrimPrefix(ref, "refs/heads/"))
+	}
+	plan.Step("git push --no-verify origin %d branches: %s", len(refs), strings.Join(refs, ", "))
+}
+
+// planUpload plans the upload of the LFS objects reachable from refs
+func planUpload(plan *common.Plan, job syncJob, repoPath, remoteURL string, refs []string, opts Options, exists bool) {
+	pointers, err := lfs.ScanPointers(repoPath, refs)
+	if err != nil {
+		plan.Problem("failed to scan LFS pointers: %v", err)
+		return
+	}
+	if len(pointers) == 0 {
+		plan.Step("no LFS objects referenced")
+		return
+	}
+
+	store, err := lfs.NewLocalStore(repoPath)
+	if err != nil {
+		plan.Problem("%v", err)
+		return
+	}
+	var absent int
+	for _, p := range pointers {
+		if !store.Has(p) {
+			absent++
+		}
+	}
+	if absent > 0 {
+		if opts.AllowIncomplete {
+			plan.Note("%d LFS objects are missing locally and would be left out", absent)
+		} else {
+			plan.Problem("%d LFS objects are missing locally, pull again or use --allow-incomplete", absent)
+		}
+	}
+
+	planObjects(plan, "upload", pointers, lfs.NewClient(lfs.EndpointForRepo(remoteURL), opts.Token), job.target, exists)
+}
+
+// planDirect plans the streaming of LFS objects from the source server
+func planDirect(plan *common.Plan, job syncJob, opts Options, exists bool) {
+	sourceURL, err := common.ValidateCloneURL(job.record.CloneURL, job.record.SourceHost)
+	if err != nil {
+		plan.Problem("invalid clone URL: %v", err)
+		return
+	}
+
+	pointers, err := lfs.ScanRemotePointers(job.record.SourceOwner(), job.repoName, opts.SourceToken, opts.SourceHostname)
+	if err != nil {
+		plan.Problem("failed to scan LFS pointers of %s: %v", job.repoName, err)
+		return
+	}
+	pointers = lfs.UniquePointers(pointers)
+	if len(pointers) == 0 {
+		plan.Step("no LFS objects referenced")
+		return
+	}
+
+	target := lfs.NewClient(lfs.EndpointForRepo(job.target.CloneURL(opts.Hostname)), opts.Token)
+	planObjects(plan, "stream from "+sourceURL.Host, pointers, target, job.target, exists)
+}
+
+// planObjects asks the target which of pointers it still needs and adds
+// the transfer to the plan. A target that doesn't exist yet needs them all.
+func planObjects(plan *common.Plan, action string, pointers []lfs.Pointer, client *lfs.Client, target common.Target, exists bool) {
+	needed := make(map[string]bool, len(pointers))
+	for _, p := range pointers {
+		needed[p.OID] = true
+	}
+
+	if exists {
+		specs := make([]lfs.ObjectSpec, 0, len(pointers))
+		for _, p := range pointers {
+			specs = append(specs, lfs.ObjectSpec{OID: p.OID, Size: p.Size})
+		}
+		response, err := client.Batch(lfs.OperationUpload, specs)
+		if err != nil {
+			plan.Problem("can't check LFS objects on %s: %v", target, err)
+			return
+		}
+		for _, obj := range response.Objects {
+			if obj.Error == nil && obj.Actions[lfs.OperationUpload] == nil {
+				delete(needed, obj.OID)
+			}
+		}
+	}
+
+	var transferObjects, presentObjects int
+	var transferBytes, presentBytes int64
+	for _, p := range pointers {
+		if needed[p.OID] {
+			transferObjects++
+			transferBytes += p.Size
+		} else {
+			presentObjects++
+			presentBytes += p.Size
+		}
+	}
+	plan.Step("%s %d LFS objects (%s) to %s, %d already there (%s)", action,
+		transferObjects, common.FormatBytes(transferBytes), target, presentObjects, common.FormatBytes(presentBytes))
+}
@@ -0,0 +1,134 @@
+package sync
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/api"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+	"github.com/pterm/pterm"
+)
+
+// preflightResult splits sync jobs by the state of their target repository
+type preflightResult struct {
+	ready    []syncJob
+	missing  []syncJob
+	archived []syncJob
+	failed   []syncJob
+}
+
+// preflightTargets checks that every target repository exists and isn't
+// archived. With createMissing set, missing repositories are created using
+// the visibility, description and default branch of the source repository.
+func preflightTargets(jobs []syncJob, createMissing bool, hostname, token string) preflightResult {
+	var result preflightResult
+
+	spinner, _ := pterm.DefaultSpinner.Start("Checking target repositories...")
+	for _, job := range jobs {
+		repo, err := api.GetRepository(job.target.Owner, job.target.Name, token, hostname)
+		if err != nil {
+			pterm.Error.Printf("Failed to check target repository %s: %v\n", job.target, err)
+			result.failed = append(result.failed, job)
+			continue
+		}
+
+		switch {
+		case repo == nil && createMissing:
+			if err := createTarget(&job, hostname, token); err != nil {
+				pterm.Error.Printf("%v\n", err)
+				result.failed = append(result.failed, job)
+				continue
+			}
+			pterm.Success.Printf("Created target repository %s\n", job.target)
+			result.ready = append(result.ready, job)
+		case repo == nil:
+			result.missing = append(result.missing, job)
+		case repo.GetArchived():
+			result.archived = append(result.archived, job)
+		default:
+			result.ready = append(result.ready, job)
+		}
+	}
+	spinner.Success()
+
+	if len(result.missing) > 0 {
+		pterm.Warning.Printf("%d target repositories do not exist (use --create-missing to create them):\n", len(result.missing))
+		for _, job := range result.missing {
+			common.Warnf("  - %s (source: %s)\n", job.target, job.repoName)
+		}
+	}
+
+	if len(result.archived) > 0 {
+		pterm.Warning.Printf("%d target repositories are archived and can't be pushed to:\n", len(result.archived))
+		for _, job := range result.archived {
+			common.Warnf("  - %s (source: %s)\n", job.target, job.repoName)
+		}
+	}
+
+	return result
+}
+
+// createTarget creates the target repository of a job and records the
+// default branch to set once content has been pushed
+func createTarget(job *syncJob, hostname, token string) error {
+	visibility, defaultBranch := targetSettings(*job)
+
+	if _, err := api.CreateRepository(job.target.Owner, job.target.Name, visibility, job.record.Description, token, hostname); err != nil {
+		return fmt.Errorf("❌ Failed to create target repository %s: %w", job.target, err)
+	}
+
+	job.defaultBranch = defaultBranch
+	return nil
+}
+
+// targetSettings returns the visibility and default branch a missing target
+// is created with, taken from the source repository
+func targetSettings(job syncJob) (string, string) {
+	visibility := job.record.Visibility
+	if visibility == "" {
+		visibility = "private"
+	}
+
+	defaultBranch := job.record.DefaultBranch
+	if defaultBranch == "" {
+		defaultBranch = localDefaultBranch(filepath.Join(job.workDir, job.repoName))
+	}
+	return visibility, defaultBranch
+}
+
+// localDefaultBranch returns the branch origin/HEAD pointed at when the
+// repository was cloned from the source
+func localDefaultBranch(repoPath string) string {
+	cmd := exec.Command("git", "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(output)), "origin/")
+}
+
+// applyDefaultBranch sets the default branch of a newly created target
+func applyDefaultBranch(job syncJob, hostname, token string) error {
+	if job.defaultBranch == "" {
+		return nil
+	}
+	return api.SetDefaultBranch(job.target.Owner, job.target.Name, job.defaultBranch, token, hostname)
+}
+
+// recordFailures counts jobs that were rejected before any push and marks
+// them as failed in the migration state
+func recordFailures(stats *common.ProcessStats, store *state.Store, jobs ...[]syncJob) {
+	for _, list := range jobs {
+		stats.Failed += int32(len(list))
+		for _, job := range list {
+			err := fmt.Errorf("target repository %s failed the preflight check", job.target)
+			store.Finish(job.repoName, state.PhaseSynced, err)
+			stats.RecordResult(common.NewRepoResult(job.record, state.PhaseSynced, time.Now(), err))
+		}
+	}
+}
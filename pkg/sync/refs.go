@@ -0,0 +1,184 @@
+package sync
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+// listRemoteRefs returns the branches and tags on origin keyed by ref name
+func listRemoteRefs(repoPath string, env []string) (map[string]string, error) {
+	cmd := exec.Command("git", "ls-remote", "--heads", "--tags", "origin")
+	cmd.Dir = repoPath
+	cmd.Env = env
+	output, err := common.RepoLogger(filepath.Base(repoPath)).Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target refs: %w", err)
+	}
+
+	refs := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		// Peeled tags point at the tagged commit, the tag itself is enough
+		if strings.HasSuffix(fields[1], "^{}") {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+
+	return refs, nil
+}
+
+// commitExists reports whether a commit is present in the local repository
+func commitExists(repoPath, sha string, env []string) bool {
+	cmd := exec.Command("git", "cat-file", "-e", sha+"^{commit}")
+	cmd.Dir = repoPath
+	cmd.Env = env
+	_, err := common.RepoLogger(filepath.Base(repoPath)).Run(cmd)
+	return err == nil
+}
+
+// Ref states when comparing local branches with the target
+const (
+	refIdentical   = "identical"
+	refFastForward = "fast-forward"
+	refDiverged    = "diverged"
+	refMissing     = "missing"
+)
+
+// refComparison is the state of one local branch relative to the target
+type refComparison struct {
+	ref    string
+	local  string
+	remote string
+	status string
+}
+
+// listLocalBranches returns the local branches keyed by ref name
+func listLocalBranches(repoPath string, env []string) (map[string]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname) %(objectname)", "refs/heads")
+	cmd.Dir = repoPath
+	cmd.Env = env
+	output, err := common.RepoLogger(filepath.Base(repoPath)).Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local branches: %w", err)
+	}
+
+	refs := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		refs[fields[0]] = fields[1]
+	}
+
+	return refs, nil
+}
+
+// isAncestor reports whether ancestor is reachable from commit
+func isAncestor(repoPath, ancestor, commit string, env []string) bool {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", ancestor, commit)
+	cmd.Dir = repoPath
+	cmd.Env = env
+	_, err := common.RepoLogger(filepath.Base(repoPath)).Run(cmd)
+	return err == nil
+}
+
+// compareRefs classifies every local branch against the target. A target
+// commit that isn't in the local clone can't be fast-forwarded from, so it
+// counts as diverged.
+func compareRefs(repoPath string, local, remote map[string]string, env []string) []refComparison {
+	var comparisons []refComparison
+	for ref, localSHA := range local {
+		comparison := refComparison{ref: ref, local: localSHA, remote: remote[ref]}
+
+		switch {
+		case comparison.remote == "":
+			comparison.status = refMissing
+		case comparison.remote == localSHA:
+			comparison.status = refIdentical
+		case commitExists(repoPath, comparison.remote, env) && isAncestor(repoPath, comparison.remote, localSHA, env):
+			comparison.status = refFastForward
+		default:
+			comparison.status = refDiverged
+		}
+
+		comparisons = append(comparisons, comparison)
+	}
+
+	sort.Slice(comparisons, func(i, j int) bool {
+		return comparisons[i].ref < comparisons[j].ref
+	})
+
+	return comparisons
+}
+
+// pushableRefspecs returns refspecs for the branches that can be pushed
+// without overwriting work on the target
+func pushableRefspecs(comparisons []refComparison) []string {
+	var refspecs []string
+	for _, c := range comparisons {
+		if c.status == refMissing || c.status == refFastForward {
+			refspecs = append(refspecs, c.ref+":"+c.ref)
+		}
+	}
+	return refspecs
+}
+
+// pushableRefs returns the branches that pushableRefspecs pushes
+func pushableRefs(comparisons []refComparison) []string {
+	var refs []string
+	for _, c := range comparisons {
+		if c.status == refMissing || c.status == refFastForward {
+			refs = append(refs, c.ref)
+		}
+	}
+	return refs
+}
+
+// uploadRefs returns the branches whose LFS objects are uploaded: every
+// branch that isn't diverged. Identical branches are included since their
+// objects may still be missing, for example after GEI migrated the git data.
+func uploadRefs(comparisons []refComparison) []string {
+	var refs []string
+	for _, c := range comparisons {
+		if c.status != refDiverged {
+			refs = append(refs, c.ref)
+		}
+	}
+	return refs
+}
+
+// printRefReport summarizes the ref comparison of a repository, listing
+// each diverged branch since those are skipped
+func printRefReport(repoName string, comparisons []refComparison) {
+	counts := make(map[string]int)
+	for _, c := range comparisons {
+		counts[c.status]++
+	}
+
+	common.Infof("Refs for %s: %d identical, %d fast-forward, %d missing, %d diverged\n",
+		repoName, counts[refIdentical], counts[refFastForward], counts[refMissing], counts[refDiverged])
+
+	for _, c := range comparisons {
+		if c.status == refDiverged {
+			common.Warnf("⚠️  Skipping diverged ref %s of %s (local %s, target %s)\n",
+				c.ref, repoName, shortSHA(c.local), shortSHA(c.remote))
+		}
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,168 @@
+package sync
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// git runs a git command in dir and returns its trimmed output
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// commit adds a commit changing file and returns its SHA
+func commit(t *testing.T, dir, file string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(file+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", file)
+	git(t, dir, "commit", "-q", "-m", file)
+	return git(t, dir, "rev-parse", "HEAD")
+}
+
+func TestCompareRefs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git(t, dir, "init", "-q", "-b", "main")
+	base := commit(t, dir, "base")
+	head := commit(t, dir, "head")
+
+	git(t, dir, "checkout", "-q", "-b", "rewritten", base)
+	rewritten := commit(t, dir, "rewritten")
+	git(t, dir, "branch", "-q", "identical", head)
+	git(t, dir, "branch", "-q", "new", head)
+	git(t, dir, "branch", "-q", "behind", base)
+
+	local, err := listLocalBranches(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLocal := map[string]string{
+		"refs/heads/main":      head,
+		"refs/heads/rewritten": rewritten,
+		"refs/heads/identical": head,
+		"refs/heads/new":       head,
+		"refs/heads/behind":    base,
+	}
+	if !reflect.DeepEqual(local, wantLocal) {
+		t.Fatalf("listLocalBranches() = %v, want %v", local, wantLocal)
+	}
+
+	unknown := strings.Repeat("f", 40)
+	remote := map[string]string{
+		"refs/heads/main":      base,      // local is ahead
+		"refs/heads/rewritten": head,      // history was rewritten locally
+		"refs/heads/identical": head,      // nothing to push
+		"refs/heads/behind":    head,      // target is ahead of the clone
+		"refs/heads/remote":    rewritten, // not a local branch
+	}
+
+	tests := []struct {
+		name   string
+		remote map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "mixed states",
+			remote: remote,
+			want: map[string]string{
+				"refs/heads/behind":    refDiverged,
+				"refs/heads/identical": refIdentical,
+				"refs/heads/main":      refFastForward,
+				"refs/heads/new":       refMissing,
+				"refs/heads/rewritten": refDiverged,
+			},
+		},
+		{
+			name:   "target commit not in the clone",
+			remote: map[string]string{"refs/heads/main": unknown},
+			want: map[string]string{
+				"refs/heads/behind":    refMissing,
+				"refs/heads/identical": refMissing,
+				"refs/heads/main":      refDiverged,
+				"refs/heads/new":       refMissing,
+				"refs/heads/rewritten": refMissing,
+			},
+		},
+		{
+			name:   "empty target",
+			remote: map[string]string{},
+			want: map[string]string{
+				"refs/heads/behind":    refMissing,
+				"refs/heads/identical": refMissing,
+				"refs/heads/main":      refMissing,
+				"refs/heads/new":       refMissing,
+				"refs/heads/rewritten": refMissing,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparisons := compareRefs(dir, local, tt.remote, nil)
+
+			got := make(map[string]string)
+			var order []string
+			for _, c := range comparisons {
+				got[c.ref] = c.status
+				order = append(order, c.ref)
+				if c.local != local[c.ref] || c.remote != tt.remote[c.ref] {
+					t.Errorf("%s compared %s with %s, want %s with %s", c.ref, c.local, c.remote, local[c.ref], tt.remote[c.ref])
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareRefs() = %v, want %v", got, tt.want)
+			}
+			if !sort.StringsAreSorted(order) {
+				t.Errorf("compareRefs() isn't sorted by ref: %v", order)
+			}
+		})
+	}
+}
+
+func TestRefSelection(t *testing.T) {
+	comparisons := []refComparison{
+		{ref: "refs/heads/a", status: refIdentical},
+		{ref: "refs/heads/b", status: refFastForward},
+		{ref: "refs/heads/c", status: refMissing},
+		{ref: "refs/heads/d", status: refDiverged},
+	}
+
+	if got, want := pushableRefs(comparisons), []string{"refs/heads/b", "refs/heads/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pushableRefs() = %v, want %v", got, want)
+	}
+	if got, want := pushableRefspecs(comparisons), []string{"refs/heads/b:refs/heads/b", "refs/heads/c:refs/heads/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pushableRefspecs() = %v, want %v", got, want)
+	}
+	if got := pushableRefs(comparisons[:1]); got != nil {
+		t.Errorf("pushableRefs() of identical refs = %v, want none", got)
+	}
+
+	// Identical branches are already on the target but their LFS objects
+	// may not be, only diverged branches are left out of the upload
+	if got, want := uploadRefs(comparisons), []string{"refs/heads/a", "refs/heads/b", "refs/heads/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uploadRefs() = %v, want %v", got, want)
+	}
+	if got, want := uploadRefs(comparisons[:1]), []string{"refs/heads/a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("uploadRefs() of identical refs = %v, want %v", got, want)
+	}
+	if got := uploadRefs(comparisons[3:]); got != nil {
+		t.Errorf("uploadRefs() of diverged refs = %v, want none", got)
+	}
+}
@@ -1,162 +1,421 @@
 package sync
 
 import (
-	"encoding/csv"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
 	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
 	"github.com/spf13/viper"
 )
 
+// Ref sources for LFS-only syncs
+const (
+	RefSourceLocal  = "local"
+	RefSourceTarget = "target"
+)
+
+// Options controls how content is pushed to a target repository
+type Options struct {
+	Hostname string
+	Token    string
+
+	// LFSOnly pushes LFS objects without pushing any git refs, for targets
+	// whose git data was already migrated
+	LFSOnly bool
+
+	// RefSource selects the refs whose LFS objects are pushed in LFS-only
+	// mode: the local refs or the refs that already exist on the target
+	RefSource string
+
+	// Verify checks that every LFS object arrived on the target after a
+	// repository has been synced
+	Verify bool
+
+	// AllowIncomplete pushes repositories with corrupt or missing local LFS
+	// objects, leaving those objects out
+	AllowIncomplete bool
+
+	// Direct streams LFS objects from the source server to the target
+	// without a clone, for targets whose git data was already migrated
+	Direct         bool
+	SourceHostname string
+	SourceToken    string
+
+	// SpoolSize bounds the temporary disk space used to buffer objects whose
+	// direct stream has to be retried
+	SpoolSize int64
+}
+
 type syncJob struct {
-	repoName  string
-	workDir   string
-	targetOrg string
+	repoName      string
+	workDir       string
+	target        common.Target
+	record        common.InventoryRecord
+	defaultBranch string // set when the target was created by the preflight
+
+	// verifyOnly is set for repositories that synced in an earlier run and
+	// still need to be verified
+	verifyOnly bool
 }
 
 func SyncFromCSV() error {
+	records, err := common.ReadInventory(viper.GetString("GHMLFS_FILE"))
+	if err != nil {
+		return err
+	}
+
+	return SyncRecords(records)
+}
+
+// selectRecords returns the records to sync in mode. With verify, records
+// that synced in an earlier run but weren't verified are selected too, and
+// returned in verifyOnly.
+func selectRecords(store *state.Store, records []common.InventoryRecord, mode string, verify bool) ([]common.InventoryRecord, map[string]bool) {
+	selected := store.Select(records, state.PhaseSynced, mode)
+	verifyOnly := make(map[string]bool)
+	if !verify || mode == state.SelectAll {
+		return selected, verifyOnly
+	}
+
+	syncing := make(map[string]bool, len(selected))
+	for _, record := range selected {
+		syncing[record.Repository] = true
+	}
+	for _, record := range records {
+		if !syncing[record.Repository] && store.Selected(record.Repository, state.PhaseVerified, mode) {
+			verifyOnly[record.Repository] = true
+			selected = append(selected, record)
+		}
+	}
+	if len(verifyOnly) > 0 {
+		common.Infof("🔁 %s: %d synced repositories selected for %s only\n", mode, len(verifyOnly), state.PhaseVerified)
+	}
+	return selected, verifyOnly
+}
+
+// SyncRecords pushes the repositories of an inventory to their targets
+func SyncRecords(records []common.InventoryRecord) error {
 	// Get configuration from viper
-	inputFile := viper.GetString("GHMLFS_FILE")
+	mappingFile := viper.GetString("GHMLFS_MAPPING_FILE")
 	workDir := viper.GetString("GHMLFS_WORK_DIR")
 	targetOrg := viper.GetString("GHMLFS_TARGET_ORGANIZATION")
+	hostname := viper.GetString("GHMLFS_TARGET_HOSTNAME")
 	token := viper.GetString("GHMLFS_TARGET_TOKEN")
 	maxWorkers := viper.GetInt("GHMLFS_WORKERS")
+	createMissing := viper.GetBool("GHMLFS_CREATE_MISSING")
+	common.RegisterSecrets(token, viper.GetString("GHMLFS_SOURCE_TOKEN"))
+	common.ConfigureRepoLogs(workDir)
+	opts := Options{
+		Hostname:  hostname,
+		Token:     token,
+		LFSOnly:   viper.GetBool("GHMLFS_LFS_ONLY"),
+		RefSource: viper.GetString("GHMLFS_REF_SOURCE"),
+		Verify:    viper.GetBool("GHMLFS_VERIFY"),
+
+		AllowIncomplete: viper.GetBool("GHMLFS_ALLOW_INCOMPLETE"),
+
+		Direct:         viper.GetBool("GHMLFS_DIRECT"),
+		SourceHostname: viper.GetString("GHMLFS_SOURCE_HOSTNAME"),
+		SourceToken:    viper.GetString("GHMLFS_SOURCE_TOKEN"),
+	}
 
-	// Read CSV file
-	file, err := os.Open(inputFile)
+	// Neither mode pushes branches, so a created target would stay empty and
+	// its default branch couldn't be set
+	if createMissing && (opts.LFSOnly || opts.Direct) {
+		return fmt.Errorf("--create-missing can't be used with --lfs-only or --direct, which don't push any git refs")
+	}
+
+	if opts.Direct {
+		if opts.SourceToken == "" {
+			return fmt.Errorf("--direct requires a source token")
+		}
+		spoolSize, err := common.ParseBytes(viper.GetString("GHMLFS_SPOOL_SIZE"))
+		if err != nil {
+			return fmt.Errorf("invalid spool size: %w", err)
+		}
+		opts.SpoolSize = spoolSize
+	}
+	spool := lfs.NewSpool("", opts.SpoolSize)
+
+	if opts.RefSource == "" {
+		opts.RefSource = RefSourceLocal
+	}
+	if opts.RefSource != RefSourceLocal && opts.RefSource != RefSourceTarget {
+		return fmt.Errorf("invalid ref source %q, expected %s or %s", opts.RefSource, RefSourceLocal, RefSourceTarget)
+	}
+
+	store, mode, err := state.OpenForRun(workDir)
 	if err != nil {
-		return fmt.Errorf("error opening input file: %w", err)
+		return err
+	}
+	records, verifyOnly := selectRecords(store, records, mode, opts.Verify)
+
+	mappings, err := common.LoadMappings(mappingFile)
+	if err != nil {
+		return err
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	// Skip header
-	if _, err := reader.Read(); err != nil {
-		return fmt.Errorf("error reading CSV header: %w", err)
+	// Resolve every target up front so a bad mapping fails before any push
+	var syncJobs []syncJob
+	for _, record := range records {
+		target, err := common.ResolveTarget(record, mappings, targetOrg)
+		if err != nil {
+			return fmt.Errorf("failed to resolve target for %s: %w", record.Repository, err)
+		}
+		syncJobs = append(syncJobs, syncJob{
+			repoName:   record.Repository,
+			workDir:    workDir,
+			target:     target,
+			record:     record,
+			verifyOnly: verifyOnly[record.Repository],
+		})
+	}
+
+	if viper.GetBool("GHMLFS_DRY_RUN") {
+		return planSync(syncJobs, opts, createMissing)
 	}
 
-	// Create jobs channel and track unique repositories
+	// Check target repositories before pushing anything
+	preflight := preflightTargets(syncJobs, createMissing, hostname, token)
+	syncJobs = preflight.ready
+
+	// Create jobs channel
 	jobs := make(chan syncJob)
-	seen := make(map[string]bool)
 
 	// Start goroutine to send jobs
 	go func() {
 		defer close(jobs)
-		for {
-			record, err := reader.Read()
-			if err != nil {
-				break
-			}
-			repoName := record[0]
-			if seen[repoName] {
-				continue
-			}
-			seen[repoName] = true
-
-			jobs <- syncJob{
-				repoName:  repoName,
-				workDir:   workDir,
-				targetOrg: targetOrg,
-			}
+		for _, job := range syncJobs {
+			jobs <- job
 		}
 	}()
 
 	// Create and run worker pool
 	stats := common.NewProcessStats()
-	err = common.WorkerPool(jobs, maxWorkers, stats, func(job syncJob) error {
+	recordFailures(stats, store, preflight.missing, preflight.archived, preflight.failed)
+	expected := make([]common.InventoryRecord, 0, len(syncJobs))
+	for _, job := range syncJobs {
+		expected = append(expected, job.record)
+	}
+	stats.Expect(expected)
+	// syncRepo pushes a repository. For direct syncs it returns what was
+	// scanned on the source, for the verification.
+	syncRepo := func(job syncJob) (*directScan, error) {
+		if opts.Direct {
+			transferred, scan, err := syncDirect(job, opts, spool)
+			stats.RecordTransfer(job.repoName, transferred)
+			if err != nil {
+				return nil, err
+			}
+			return scan, applyDefaultBranch(job, hostname, token)
+		}
+
 		// Pass token here instead of in the job struct for better security
-		return SyncLFSContent(job.repoName, job.workDir, job.targetOrg, token)
+		transferred, err := SyncLFSContent(job.repoName, job.workDir, job.target.Owner, job.target.Name, opts)
+		stats.RecordTransfer(job.repoName, transferred)
+		if err != nil {
+			return nil, err
+		}
+		return nil, applyDefaultBranch(job, hostname, token)
+	}
+
+	// Verification is its own phase once the sync succeeded, so a repository
+	// that fails it is verified again on --resume without pushing again
+	process := func(job syncJob) error {
+		var scan *directScan
+		if !job.verifyOnly {
+			err := store.Track(job.repoName, state.PhaseSynced, func() error {
+				var err error
+				scan, err = syncRepo(job)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if !opts.Verify {
+			return nil
+		}
+		return store.Track(job.repoName, state.PhaseVerified, func() error {
+			if opts.Direct {
+				return verifyDirect(job, scan, opts)
+			}
+			return verifyTarget(job, opts)
+		})
+	}
+	err = common.WorkerPool(jobs, maxWorkers, stats, func(job syncJob) error {
+		phase := state.PhaseSynced
+		if job.verifyOnly {
+			phase = state.PhaseVerified
+		}
+		return stats.Track(job.record, phase, func() error {
+			return process(job)
+		})
 	})
 
 	// Print summary
 	stats.PrintSummary(workDir)
+	if resultsErr := stats.WriteResults(workDir, "sync"); resultsErr != nil {
+		return resultsErr
+	}
 
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("\n✅ Sync completed successfully!")
+	common.Println("\n✅ Sync completed successfully!")
 	return nil
 }
 
-func SyncLFSContent(repoName, workDir, targetOrg, token string) error {
+func SyncLFSContent(repoName, workDir, targetOrg, targetName string, opts Options) (common.TransferStats, error) {
 	repoPath := filepath.Join(workDir, repoName)
+	token := opts.Token
+	log := common.RepoLogger(repoName)
 
 	// Configure GitHub authentication
 	authCmd := exec.Command("sh", "-c", fmt.Sprintf("echo %q | gh auth login --with-token", token))
-	if output, err := authCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("❌ Failed to configure GitHub authentication: %s, %w", string(output), err)
+	if output, err := log.Run(authCmd); err != nil {
+		return common.TransferStats{}, fmt.Errorf("❌ Failed to configure GitHub authentication: %s, %w", common.Redact(string(output)), err)
 	}
 
 	// Configure git credential helper
 	credCmd := exec.Command("git", "config", "--global", "credential.helper", "!gh auth git-credential")
-	if output, err := credCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("❌ Failed to configure git credential helper: %s, %w", string(output), err)
+	if output, err := log.Run(credCmd); err != nil {
+		return common.TransferStats{}, fmt.Errorf("❌ Failed to configure git credential helper: %s, %w", common.Redact(string(output)), err)
 	}
 
-	// Set environment variables
-	env := append(os.Environ(),
-		"GIT_LFS_SKIP_SMUDGE=1",
-		"GIT_TERMINAL_PROMPT=0",
-		"GIT_TRACE=1",
-		"GIT_CURL_VERBOSE=1",
-	)
+	env := gitEnv()
 
-	fmt.Printf("Syncing %s to %s/%s...\n", repoName, targetOrg, repoName)
-
-	// Initialize Git LFS
-	lfsInstallCmd := exec.Command("git", "lfs", "install")
-	lfsInstallCmd.Dir = repoPath
-	lfsInstallCmd.Env = env
-	if err := lfsInstallCmd.Run(); err != nil {
-		return fmt.Errorf("failed to install git lfs: %w", err)
-	}
+	common.Infof("Syncing %s to %s/%s...\n", repoName, targetOrg, targetName)
 
 	// Set the remote URL without embedding the token
-	baseURL := fmt.Sprintf("https://github.com/%s/%s.git", targetOrg, repoName)
+	baseURL := common.Target{Owner: targetOrg, Name: targetName}.CloneURL(opts.Hostname)
 	remoteCmd := exec.Command("git", "remote", "set-url", "origin", baseURL)
 	remoteCmd.Dir = repoPath
 	remoteCmd.Env = env
-	if err := remoteCmd.Run(); err != nil {
-		return fmt.Errorf("failed to set remote url: %w", err)
+	if _, err := log.Run(remoteCmd); err != nil {
+		return common.TransferStats{}, fmt.Errorf("failed to set remote url: %w", err)
 	}
 
 	// Verify the remote URL
 	verifyCmd := exec.Command("git", "remote", "get-url", "origin")
 	verifyCmd.Dir = repoPath
 	verifyCmd.Env = env
-	output, err := verifyCmd.Output()
+	output, err := log.Output(verifyCmd)
 	if err != nil {
-		return fmt.Errorf("failed to get remote url: %w", err)
+		return common.TransferStats{}, fmt.Errorf("failed to get remote url: %w", err)
 	}
 	remoteURL := strings.TrimSpace(string(output))
-	fmt.Printf("Verified remote URL: %s\n", remoteURL)
-
-	// Push all branches
-	fmt.Printf("Pushing content for %s...\n", repoName)
-	pushCmd := exec.Command("git", "push", "--all", "origin")
-	pushCmd.Dir = repoPath
-	pushCmd.Env = env
-	if output, err := pushCmd.CombinedOutput(); err != nil {
-		// Mask token in error message
-		errMsg := strings.ReplaceAll(string(output), token, "****")
-		return fmt.Errorf("failed to push content: %s, %w", errMsg, err)
-	}
-
-	// Push LFS content
-	lfsPushCmd := exec.Command("git", "lfs", "push", "--all", "origin")
-	lfsPushCmd.Dir = repoPath
-	lfsPushCmd.Env = env
-	if output, err := lfsPushCmd.CombinedOutput(); err != nil {
-		// Mask token in error message
-		errMsg := strings.ReplaceAll(string(output), token, "****")
-		return fmt.Errorf("failed to push LFS content: %s, %w", errMsg, err)
-	}
-
-	fmt.Printf("Successfully synced content for %s\n", repoName)
-	return nil
+	common.Infof("Verified remote URL: %s\n", remoteURL)
+
+	// Git refs were migrated separately, only push the LFS objects they need
+	if opts.LFSOnly {
+		stats, err := pushLFSObjectsOnly(repoName, repoPath, remoteURL, opts, env)
+		if err != nil {
+			return stats, err
+		}
+		common.Infof("Successfully synced LFS objects for %s\n", repoName)
+		return stats, nil
+	}
+
+	// Compare branches with the target so work pushed there after the
+	// migration is never overwritten
+	localRefs, err := listLocalBranches(repoPath, env)
+	if err != nil {
+		return common.TransferStats{}, err
+	}
+	remoteRefs, err := listRemoteRefs(repoPath, env)
+	if err != nil {
+		return common.TransferStats{}, err
+	}
+	comparisons := compareRefs(repoPath, localRefs, remoteRefs, env)
+	printRefReport(repoName, comparisons)
+
+	// Upload the LFS objects of every branch that isn't diverged before any
+	// branch is pushed. Skipped branches don't get their content uploaded.
+	var stats common.TransferStats
+	if refs := uploadRefs(comparisons); len(refs) > 0 {
+		stats, err = uploadLFSObjects(repoName, repoPath, remoteURL, refs, opts)
+		if err != nil {
+			return stats, err
+		}
+	} else {
+		common.Infof("No branches of %s to sync, skipping LFS upload\n", repoName)
+	}
+
+	// Push branches that are missing on the target or can be fast-forwarded.
+	// The git-lfs pre-push hook is skipped, objects were uploaded above.
+	if refspecs := pushableRefspecs(comparisons); len(refspecs) > 0 {
+		common.Infof("Pushing content for %s...\n", repoName)
+		common.RepoProgress(repoName).Step("pushing refs")
+		args := append([]string{"push", "--no-verify", "origin"}, refspecs...)
+		pushCmd := exec.Command("git", args...)
+		pushCmd.Dir = repoPath
+		pushCmd.Env = env
+		if output, err := log.Run(pushCmd); err != nil {
+			return stats, fmt.Errorf("failed to push content: %s, %w", common.Redact(string(output)), err)
+		}
+	}
+
+	common.Infof("Successfully synced content for %s\n", repoName)
+	return stats, nil
+}
+
+// pushLFSObjectsOnly pushes the LFS objects referenced by either the local
+// refs or the refs already on the target, without writing any git refs
+func pushLFSObjectsOnly(repoName, repoPath, remoteURL string, opts Options, env []string) (common.TransferStats, error) {
+	if opts.RefSource != RefSourceTarget {
+		common.Infof("Pushing LFS objects for local refs of %s...\n", repoName)
+		return uploadLFSObjects(repoName, repoPath, remoteURL, nil, opts)
+	}
+
+	common.Infof("Pushing LFS objects for target refs of %s...\n", repoName)
+	commits, err := targetCommits(repoName, repoPath, env)
+	if err != nil {
+		return common.TransferStats{}, err
+	}
+	if len(commits) == 0 {
+		common.Infof("No target refs of %s match local commits, nothing to push\n", repoName)
+		return common.TransferStats{}, nil
+	}
+
+	return uploadLFSObjects(repoName, repoPath, remoteURL, commits, opts)
+}
+
+// targetCommits returns the commits of the target's refs that are in the
+// local clone, since objects can only be pushed for commits we have locally
+func targetCommits(repoName, repoPath string, env []string) ([]string, error) {
+	remoteRefs, err := listRemoteRefs(repoPath, env)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []string
+	seen := make(map[string]bool)
+	for ref, sha := range remoteRefs {
+		if seen[sha] {
+			continue
+		}
+		if !commitExists(repoPath, sha, env) {
+			common.Infof("Skipping target ref %s of %s, commit %s is not in the local clone\n", ref, repoName, sha)
+			continue
+		}
+		seen[sha] = true
+		commits = append(commits, sha)
+	}
+	return commits, nil
+}
+
+// gitEnv returns the environment of sync's git commands, git only traces
+// into the repository log at the debug level
+func gitEnv() []string {
+	return append(append(os.Environ(),
+		"GIT_LFS_SKIP_SMUDGE=1",
+		"GIT_TERMINAL_PROMPT=0",
+	), common.GitTraceEnv()...)
 }
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+)
+
+func TestSelectRecords(t *testing.T) {
+	store, err := state.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail := func() error { return errors.New("failed") }
+	succeed := func() error { return nil }
+
+	store.Track("verified", state.PhaseSynced, succeed)
+	store.Track("verified", state.PhaseVerified, succeed)
+	store.Track("unverified", state.PhaseSynced, succeed)
+	store.Track("verify-failed", state.PhaseSynced, succeed)
+	store.Track("verify-failed", state.PhaseVerified, fail)
+	store.Track("sync-failed", state.PhaseSynced, fail)
+
+	var records []common.InventoryRecord
+	for _, repo := range []string{"verified", "unverified", "verify-failed", "sync-failed", "new"} {
+		records = append(records, common.InventoryRecord{Repository: repo})
+	}
+
+	tests := []struct {
+		name           string
+		mode           string
+		verify         bool
+		wantSelected   []string
+		wantVerifyOnly map[string]bool
+	}{
+		{
+			name:           "all",
+			mode:           state.SelectAll,
+			verify:         true,
+			wantSelected:   []string{"verified", "unverified", "verify-failed", "sync-failed", "new"},
+			wantVerifyOnly: map[string]bool{},
+		},
+		{
+			name:           "resume without verify",
+			mode:           state.SelectResume,
+			wantSelected:   []string{"sync-failed", "new"},
+			wantVerifyOnly: map[string]bool{},
+		},
+		{
+			name:           "resume with verify",
+			mode:           state.SelectResume,
+			verify:         true,
+			wantSelected:   []string{"sync-failed", "new", "unverified", "verify-failed"},
+			wantVerifyOnly: map[string]bool{"unverified": true, "verify-failed": true},
+		},
+		{
+			name:           "only failed with verify",
+			mode:           state.SelectOnlyFailed,
+			verify:         true,
+			wantSelected:   []string{"sync-failed", "verify-failed"},
+			wantVerifyOnly: map[string]bool{"verify-failed": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, verifyOnly := selectRecords(store, records, tt.mode, tt.verify)
+
+			var names []string
+			for _, record := range selected {
+				names = append(names, record.Repository)
+			}
+			if !reflect.DeepEqual(names, tt.wantSelected) {
+				t.Errorf("selected %v, want %v", names, tt.wantSelected)
+			}
+			if !reflect.DeepEqual(verifyOnly, tt.wantVerifyOnly) {
+				t.Errorf("verify only %v, want %v", verifyOnly, tt.wantVerifyOnly)
+			}
+		})
+	}
+}
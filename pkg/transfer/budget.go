@@ -0,0 +1,95 @@
+package transfer
+
+import "sync"
+
+// diskBudget limits the disk space held by repositories in the pipeline.
+// Space is reserved before a repository is pulled and released once its
+// local copy has been evicted. Kept copies of failed repositories stay
+// reserved until new pulls need their space.
+type diskBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+
+	// inFlight counts the repositories holding a reservation in the pipeline
+	inFlight int
+	// kept are the copies of failed repositories on disk, oldest first
+	kept      []keptCopy
+	keptBytes int64
+}
+
+// keptCopy is the local copy of a failed repository and how to delete it
+type keptCopy struct {
+	size  int64
+	evict func()
+}
+
+// newDiskBudget returns a budget of limit bytes, 0 means unlimited
+func newDiskBudget(limit int64) *diskBudget {
+	b := &diskBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire waits until n bytes fit in the budget and reserves them. A
+// repository larger than the whole budget is let through once nothing else
+// is reserved, so it can't block the pipeline forever. Kept copies are
+// deleted, oldest first, when the reservation would fit without them or
+// nothing else is in flight.
+func (b *diskBudget) acquire(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.limit > 0 && b.used > 0 && b.used+n > b.limit {
+		if len(b.kept) > 0 && (b.inFlight == 0 || b.used-b.keptBytes+n <= b.limit) {
+			k := b.kept[0]
+			b.kept = b.kept[1:]
+			b.keptBytes -= k.size
+			b.used -= k.size
+			b.mu.Unlock()
+			k.evict()
+			b.mu.Lock()
+			continue
+		}
+		b.cond.Wait()
+	}
+	b.used += n
+	b.inFlight++
+}
+
+// adjust replaces a reservation of from bytes with to bytes, once the actual
+// size of a repository is known
+func (b *diskBudget) adjust(from, to int64) {
+	b.mu.Lock()
+	b.used += to - from
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// release returns the n bytes of an evicted repository to the budget
+func (b *diskBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.inFlight--
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// keep leaves the n bytes of a failed repository's copy reserved after it
+// left the pipeline. evict deletes the copy once a new pull needs the space.
+func (b *diskBudget) keep(n int64, evict func()) {
+	b.mu.Lock()
+	b.inFlight--
+	b.kept = append(b.kept, keptCopy{size: n, evict: evict})
+	b.keptBytes += n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// inUse returns the bytes currently reserved
+func (b *diskBudget) inUse() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
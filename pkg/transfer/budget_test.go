@@ -0,0 +1,174 @@
+package transfer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// acquireAsync starts acquiring n bytes and returns a channel closed once
+// the reservation is made
+func acquireAsync(b *diskBudget, n int64) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		b.acquire(n)
+		close(done)
+	}()
+	return done
+}
+
+func waits(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return false
+	case <-time.After(50 * time.Millisecond):
+		return true
+	}
+}
+
+func proceeds(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func TestDiskBudgetAcquire(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int64
+		reserved []int64
+		next     int64
+		wantWait bool
+	}{
+		{"unlimited", 0, []int64{1 << 40}, 1 << 40, false},
+		{"fits", 100, []int64{40}, 60, false},
+		{"over the budget", 100, []int64{40}, 61, true},
+		{"larger than the budget with nothing reserved", 100, nil, 500, false},
+		{"larger than the budget behind others", 100, []int64{1}, 500, true},
+		{"empty reservation", 100, []int64{100}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newDiskBudget(tt.limit)
+			for _, n := range tt.reserved {
+				b.acquire(n)
+			}
+
+			done := acquireAsync(b, tt.next)
+			if got := waits(done); got != tt.wantWait {
+				t.Fatalf("acquire(%d) waited = %v, want %v", tt.next, got, tt.wantWait)
+			}
+
+			// Releasing everything lets any waiting reservation through
+			for _, n := range tt.reserved {
+				b.release(n)
+			}
+			if !proceeds(done) {
+				t.Fatal("acquire still waiting with the budget empty")
+			}
+			if b.inUse() != tt.next {
+				t.Errorf("inUse() = %d, want %d", b.inUse(), tt.next)
+			}
+		})
+	}
+}
+
+func TestDiskBudgetAdjust(t *testing.T) {
+	b := newDiskBudget(100)
+	b.acquire(90)
+
+	done := acquireAsync(b, 50)
+	if !waits(done) {
+		t.Fatal("acquire didn't wait for the budget")
+	}
+
+	// The pulled repository turned out smaller than estimated
+	b.adjust(90, 30)
+	if !proceeds(done) {
+		t.Fatal("acquire still waiting after the reservation shrank")
+	}
+	if b.inUse() != 80 {
+		t.Errorf("inUse() = %d, want 80", b.inUse())
+	}
+
+	// And one that turned out larger blocks the next
+	b.adjust(30, 60)
+	done = acquireAsync(b, 10)
+	if !waits(done) {
+		t.Fatal("acquire didn't wait after the reservation grew")
+	}
+	b.release(60)
+	if !proceeds(done) {
+		t.Fatal("acquire still waiting after release")
+	}
+	if b.inUse() != 60 {
+		t.Errorf("inUse() = %d, want 60", b.inUse())
+	}
+}
+
+func TestDiskBudgetKeep(t *testing.T) {
+	b := newDiskBudget(100)
+	var evicted []string
+	keep := func(name string, n int64) {
+		b.keep(n, func() { evicted = append(evicted, name) })
+	}
+
+	b.acquire(30)
+	b.acquire(30)
+	b.acquire(40)
+	keep("first", 30)
+	keep("second", 30)
+
+	// Deleting the kept copies doesn't make room while the repository in
+	// flight holds the rest, so they stay
+	done := acquireAsync(b, 70)
+	if !waits(done) {
+		t.Fatal("acquire didn't wait with the budget full")
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("evicted %v while the pull couldn't fit anyway", evicted)
+	}
+
+	// Once it leaves, only the oldest kept copy has to go
+	b.release(40)
+	if !proceeds(done) {
+		t.Fatal("acquire still waiting after release")
+	}
+	if !reflect.DeepEqual(evicted, []string{"first"}) {
+		t.Errorf("evicted %v, want [first]", evicted)
+	}
+	if got := b.inUse(); got != 100 {
+		t.Errorf("inUse() = %d, want 100", got)
+	}
+
+	// Kept copies never hold back a pull that fits without them
+	if !proceeds(acquireAsync(b, 10)) {
+		t.Fatal("acquire waiting on a kept copy")
+	}
+	if !reflect.DeepEqual(evicted, []string{"first", "second"}) {
+		t.Errorf("evicted %v, want [first second]", evicted)
+	}
+	if got := b.inUse(); got != 80 {
+		t.Errorf("inUse() = %d, want 80", got)
+	}
+}
+
+func TestDiskBudgetKeptCopiesDontBlock(t *testing.T) {
+	b := newDiskBudget(100)
+	evicted := 0
+	b.acquire(100)
+	b.keep(100, func() { evicted++ })
+
+	// Nothing is in flight, a repository larger than the budget still
+	// gets through once the kept copy is gone
+	if !proceeds(acquireAsync(b, 500)) {
+		t.Fatal("acquire blocked by a kept copy")
+	}
+	if evicted != 1 || b.inUse() != 500 {
+		t.Errorf("evicted %d, inUse() = %d, want 1 and 500", evicted, b.inUse())
+	}
+}
@@ -0,0 +1,48 @@
+package transfer
+
+import (
+	"github.com/mona-actions/gh-migrate-lfs/internal/api"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+// planTransfer prints the pipeline each repository would go through. The
+// targets are checked read-only, since the transfer pushes to them without
+// creating any.
+func planTransfer(jobs []*repoJob, cfg Config) error {
+	plan := common.NewPlan("transfer", len(jobs))
+	if cfg.DiskBudget > 0 {
+		common.Printf("💾 Local copies are limited to a disk budget of %s\n", common.FormatBytes(cfg.DiskBudget))
+	}
+
+	for _, job := range jobs {
+		plan.Repo(job.record.Repository + " → " + job.target.String())
+
+		repo, err := api.GetRepository(job.target.Owner, job.target.Name, cfg.Sync.Token, cfg.Sync.Hostname)
+		switch {
+		case err != nil:
+			plan.Problem("can't check target repository %s: %v", job.target, err)
+			continue
+		case repo == nil:
+			plan.Problem("target repository %s doesn't exist", job.target)
+			continue
+		case repo.GetArchived():
+			plan.Problem("target repository %s is archived and can't be pushed to", job.target)
+			continue
+		}
+
+		footprint := job.record.EstimatedFootprint()
+		plan.Step("git clone %s into %s (about %s on disk)", job.cloneURL, job.path(cfg.WorkDir), common.FormatBytes(footprint))
+		if cfg.DiskBudget > 0 && footprint > cfg.DiskBudget {
+			plan.Note("larger than the disk budget, it's pulled once nothing else is held")
+		}
+		plan.Step("download the LFS objects of all refs (%s)", common.LFSEstimate(job.record))
+		plan.Step("upload the LFS objects %s doesn't have and push the branches it's missing", job.target)
+		plan.Step("verify every LFS object on %s", job.target)
+		if cfg.KeepFailed {
+			plan.Step("delete the local copy once transferred, a failed copy is kept until the disk budget needs its space")
+		} else {
+			plan.Step("delete the local copy once transferred or failed")
+		}
+	}
+	return plan.Finish()
+}
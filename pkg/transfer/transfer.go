@@ -0,0 +1,367 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	gosync "sync"
+	"time"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/internal/metrics"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/pull"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/sync"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/verify"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// Stages of the pipeline, in order
+const (
+	stagePull   = "pull"
+	stageSync   = "sync"
+	stageVerify = "verify"
+)
+
+// stagePhases maps stages to the phase reported in the results
+var stagePhases = map[string]string{
+	stagePull:   state.PhasePulled,
+	stageSync:   state.PhaseSynced,
+	stageVerify: state.PhaseVerified,
+}
+
+// Config holds the settings of a transfer run
+type Config struct {
+	WorkDir string
+
+	SourceHostname string
+	SourceToken    string
+
+	// Sync holds the target settings, LFSOnly and Verify aren't used
+	Sync sync.Options
+
+	PullWorkers   int
+	SyncWorkers   int
+	VerifyWorkers int
+
+	// DiskBudget is the disk space repositories in the pipeline may hold,
+	// 0 means unlimited
+	DiskBudget int64
+
+	// KeepFailed keeps the local copies of failed repositories for a rerun
+	// with --only-failed to reuse. They stay counted in the disk budget
+	// until new pulls need the space.
+	KeepFailed bool
+
+	// State records the phases each repository went through
+	State *state.Store
+}
+
+// repoJob is one repository moving through the pipeline
+type repoJob struct {
+	record   common.InventoryRecord
+	target   common.Target
+	cloneURL string
+
+	// reserved is the disk budget held by the repository
+	reserved int64
+	// dangling are objects missing on the source
+	dangling map[string]bool
+
+	transfer common.TransferStats
+	started  time.Time
+	stage    string
+	err      error
+}
+
+func (j *repoJob) path(workDir string) string {
+	return filepath.Join(workDir, j.record.Repository)
+}
+
+// TransferFromCSV runs every repository of the inventory through pull, sync
+// and verify, then deletes its local copy. Each stage has its own workers,
+// and new pulls wait while the disk budget is used up by repositories
+// further down the pipeline.
+func TransferFromCSV() error {
+	inputFile := viper.GetString("GHMLFS_FILE")
+	mappingFile := viper.GetString("GHMLFS_MAPPING_FILE")
+	targetOrg := viper.GetString("GHMLFS_TARGET_ORGANIZATION")
+	diskBudget := viper.GetString("GHMLFS_DISK_BUDGET")
+
+	cfg := Config{
+		WorkDir:        viper.GetString("GHMLFS_WORK_DIR"),
+		SourceHostname: viper.GetString("GHMLFS_SOURCE_HOSTNAME"),
+		SourceToken:    viper.GetString("GHMLFS_SOURCE_TOKEN"),
+		Sync: sync.Options{
+			Hostname:        viper.GetString("GHMLFS_TARGET_HOSTNAME"),
+			Token:           viper.GetString("GHMLFS_TARGET_TOKEN"),
+			RefSource:       sync.RefSourceLocal,
+			AllowIncomplete: viper.GetBool("GHMLFS_ALLOW_INCOMPLETE"),
+		},
+		PullWorkers:   viper.GetInt("GHMLFS_PULL_WORKERS"),
+		SyncWorkers:   viper.GetInt("GHMLFS_SYNC_WORKERS"),
+		VerifyWorkers: viper.GetInt("GHMLFS_VERIFY_WORKERS"),
+		KeepFailed:    viper.GetBool("GHMLFS_KEEP_FAILED"),
+	}
+
+	common.RegisterSecrets(cfg.SourceToken, cfg.Sync.Token)
+	common.ConfigureRepoLogs(cfg.WorkDir)
+
+	if diskBudget != "" {
+		parsed, err := common.ParseBytes(diskBudget)
+		if err != nil {
+			return fmt.Errorf("invalid disk budget: %w", err)
+		}
+		cfg.DiskBudget = parsed
+	}
+
+	records, err := common.ReadInventory(inputFile)
+	if err != nil {
+		return err
+	}
+
+	store, mode, err := state.OpenForRun(cfg.WorkDir)
+	if err != nil {
+		return err
+	}
+	cfg.State = store
+	records = store.Select(records, state.PhaseVerified, mode)
+
+	mappings, err := common.LoadMappings(mappingFile)
+	if err != nil {
+		return err
+	}
+
+	// Resolve every target and clone URL up front so a bad inventory fails
+	// before anything is pulled
+	var jobs []*repoJob
+	for _, record := range records {
+		target, err := common.ResolveTarget(record, mappings, targetOrg)
+		if err != nil {
+			return fmt.Errorf("failed to resolve target for %s: %w", record.Repository, err)
+		}
+		cloneURL, err := pull.ResolveCloneURL(record.CloneURL, record.SourceHost, cfg.SourceHostname)
+		if err != nil {
+			return fmt.Errorf("❌ Invalid clone URL for %s: %w", record.Repository, err)
+		}
+		jobs = append(jobs, &repoJob{record: record, target: target, cloneURL: cloneURL})
+	}
+
+	if viper.GetBool("GHMLFS_DRY_RUN") {
+		return planTransfer(jobs, cfg)
+	}
+
+	if err := os.MkdirAll(cfg.WorkDir, 0755); err != nil {
+		return fmt.Errorf("❌ Failed to create working directory: %w", err)
+	}
+
+	stats := run(jobs, cfg)
+	stats.PrintSummary(cfg.WorkDir)
+	if err := stats.WriteResults(cfg.WorkDir, "transfer"); err != nil {
+		return err
+	}
+
+	if stats.Failed > 0 {
+		return fmt.Errorf("failed to transfer %d repositories", stats.Failed)
+	}
+
+	common.Println("\n✅ Transfer completed successfully!")
+	return nil
+}
+
+// run moves jobs through the pipeline and returns the per-repository results
+func run(jobs []*repoJob, cfg Config) *common.ProcessStats {
+	budget := newDiskBudget(cfg.DiskBudget)
+	if cfg.DiskBudget > 0 {
+		pterm.Info.Printf("Disk budget: %s\n", common.FormatBytes(cfg.DiskBudget))
+	}
+
+	records := make([]common.InventoryRecord, len(jobs))
+	for i, job := range jobs {
+		records[i] = job.record
+	}
+	progress := common.StartProgress(records)
+	defer progress.Stop()
+
+	toPull := make(chan *repoJob)
+	go func() {
+		defer close(toPull)
+		for _, job := range jobs {
+			job.reserved = job.record.EstimatedFootprint()
+			budget.acquire(job.reserved)
+			job.started = time.Now()
+			toPull <- job
+		}
+	}()
+
+	pulled := runStage(stagePull, cfg.PullWorkers, toPull, func(job *repoJob) error {
+		return cfg.State.Track(job.record.Repository, state.PhasePulled, func() error {
+			return pullStage(job, cfg, budget)
+		})
+	})
+	synced := runStage(stageSync, cfg.SyncWorkers, pulled, func(job *repoJob) error {
+		return cfg.State.Track(job.record.Repository, state.PhaseSynced, func() error {
+			return syncStage(job, cfg)
+		})
+	})
+	verified := runStage(stageVerify, cfg.VerifyWorkers, synced, func(job *repoJob) error {
+		return cfg.State.Track(job.record.Repository, state.PhaseVerified, func() error {
+			return verifyStage(job, cfg)
+		})
+	})
+
+	stats := common.NewProcessStats()
+	for job := range verified {
+		if job.err != nil && cfg.KeepFailed {
+			pterm.Info.Printf("Keeping local copy of failed '%s' for a rerun\n", job.record.Repository)
+			kept := job
+			budget.keep(job.reserved, func() {
+				pterm.Info.Printf("Deleting kept copy of '%s' to make room in the disk budget\n", kept.record.Repository)
+				evict(kept, cfg.WorkDir)
+			})
+		} else {
+			evict(job, cfg.WorkDir)
+			budget.release(job.reserved)
+		}
+		if cfg.DiskBudget > 0 {
+			pterm.Info.Printf("Released '%s', disk budget in use: %s of %s\n", job.record.Repository,
+				common.FormatBytes(budget.inUse()), common.FormatBytes(cfg.DiskBudget))
+		}
+		stats.RecordTransfer(job.record.Repository, job.transfer)
+
+		err := job.err
+		switch {
+		case job.err != nil:
+			pterm.Error.Printf("%s failed during %s: %v\n", job.record.Repository, job.stage, job.err)
+			stats.Failed++
+		case len(job.dangling) > 0:
+			pterm.Warning.Printf("%s transferred without %d LFS objects missing on the source\n", job.record.Repository, len(job.dangling))
+			stats.Partial++
+			err = &common.PartialError{Err: fmt.Errorf("%d LFS objects are missing on the source", len(job.dangling))}
+		default:
+			pterm.Success.Printf("%s transferred to %s\n", job.record.Repository, job.target)
+			stats.Processed++
+		}
+		stats.RecordResult(common.NewRepoResult(job.record, stagePhases[job.stage], job.started, err))
+		common.RepoProgress(job.record.Repository).Done()
+	}
+
+	return stats
+}
+
+// runStage starts workers that apply fn to each job from in. Jobs that
+// already failed are passed along untouched, so they still reach eviction.
+func runStage(stage string, workers int, in <-chan *repoJob, fn func(*repoJob) error) <-chan *repoJob {
+	if workers < 1 {
+		workers = 1
+	}
+
+	out := make(chan *repoJob)
+	var wg gosync.WaitGroup
+	metrics.AddWorkers(workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range in {
+				if job.err == nil {
+					job.stage = stage
+					activity := common.BeginRepo(job.record, stagePhases[stage])
+					idle := metrics.Busy()
+					job.err = fn(job)
+					idle()
+					activity.Step("waiting for the next stage")
+				}
+				out <- job
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		metrics.AddWorkers(-workers)
+		close(out)
+	}()
+	return out
+}
+
+// pullStage clones the repository and downloads its LFS objects, then
+// replaces the estimated disk reservation with the actual size
+func pullStage(job *repoJob, cfg Config, budget *diskBudget) error {
+	name := job.record.Repository
+
+	result, err := pull.PullLFSContent(name, job.cloneURL, cfg.SourceToken, cfg.WorkDir, "")
+	var partial *common.PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return err
+	}
+
+	job.dangling = make(map[string]bool)
+	for _, p := range result.Missing {
+		job.dangling[p.OID] = true
+	}
+
+	actual := common.DirSize(job.path(cfg.WorkDir))
+	budget.adjust(job.reserved, actual)
+	job.reserved = actual
+
+	_, err = pull.CheckIntegrity(name, job.path(cfg.WorkDir), job.dangling)
+	return err
+}
+
+// syncStage pushes refs and LFS objects to the target
+func syncStage(job *repoJob, cfg Config) error {
+	opts := cfg.Sync
+	if len(job.dangling) > 0 && !opts.AllowIncomplete {
+		return fmt.Errorf("%d LFS objects are missing on the source, use --allow-incomplete to push without them", len(job.dangling))
+	}
+
+	transferred, err := sync.SyncLFSContent(job.record.Repository, cfg.WorkDir, job.target.Owner, job.target.Name, opts)
+	job.transfer = transferred
+	return err
+}
+
+// verifyStage checks that every LFS object sync uploaded reached the target.
+// Objects missing on the source can't be there and are left out.
+func verifyStage(job *repoJob, cfg Config) error {
+	refs, err := sync.SyncedRefs(job.record.Repository, job.path(cfg.WorkDir), cfg.Sync)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	report, err := verify.VerifyRepository(job.record.Repository, job.path(cfg.WorkDir), refs, job.target, cfg.Sync.Hostname, cfg.Sync.Token)
+	if err != nil {
+		return err
+	}
+
+	report.Missing = withoutDangling(report.Missing, job.dangling)
+	if report.HasGaps() {
+		verify.PrintReports([]verify.Report{report})
+		return fmt.Errorf("%d missing and %d mismatched objects on %s: %w",
+			len(report.Missing), len(report.SizeMismatch), job.target, verify.ErrGaps)
+	}
+	return nil
+}
+
+func withoutDangling(pointers []lfs.Pointer, dangling map[string]bool) []lfs.Pointer {
+	var kept []lfs.Pointer
+	for _, p := range pointers {
+		if !dangling[p.OID] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// evict deletes the local copy of a repository to free its disk space
+func evict(job *repoJob, workDir string) {
+	if err := os.RemoveAll(job.path(workDir)); err != nil {
+		pterm.Warning.Printf("Failed to delete local copy of '%s': %v\n", job.record.Repository, err)
+	}
+}
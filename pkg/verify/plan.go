@@ -0,0 +1,39 @@
+package verify
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+)
+
+// planVerify prints which objects a verification would check on each
+// target and where its report would go
+func planVerify(jobs []verifyJob, workDir string) error {
+	plan := common.NewPlan("verify", len(jobs))
+	for _, job := range jobs {
+		plan.Repo(job.repoName + " → " + job.target.String())
+
+		repoPath := filepath.Join(workDir, job.repoName)
+		if _, err := os.Stat(repoPath); err != nil {
+			plan.Problem("local clone %s not found, run pull first", repoPath)
+			continue
+		}
+
+		pointers, err := lfs.ScanPointers(repoPath, nil)
+		if err != nil {
+			plan.Problem("failed to scan LFS pointers: %v", err)
+			continue
+		}
+
+		var size int64
+		for _, p := range pointers {
+			size += p.Size
+		}
+		plan.Step("check %d LFS objects (%s) on %s", len(pointers), common.FormatBytes(size), job.target)
+	}
+
+	plan.Output("the verification report", filepath.Join(workDir, ReportFile))
+	return plan.Finish()
+}
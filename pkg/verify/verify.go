@@ -0,0 +1,324 @@
+package verify
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/mona-actions/gh-migrate-lfs/internal/lfs"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/common"
+	"github.com/mona-actions/gh-migrate-lfs/pkg/state"
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+)
+
+// ErrGaps is returned when objects are missing or mismatched on the target
+var ErrGaps = common.WithClass(common.ErrorClassMismatch, errors.New("LFS objects are missing or mismatched on the target"))
+
+// ReportFile is the name of the verification report written to the work dir
+const ReportFile = "lfs_verify_report.csv"
+
+// maxListedObjects limits how many missing objects are printed per repository
+const maxListedObjects = 10
+
+// Report is the verification result of one repository
+type Report struct {
+	Repository   string
+	Target       string
+	Present      []lfs.Pointer
+	Missing      []lfs.Pointer
+	SizeMismatch []lfs.Pointer
+}
+
+// Expected returns the number of objects that were checked
+func (r Report) Expected() int {
+	return len(r.Present) + len(r.Missing) + len(r.SizeMismatch)
+}
+
+// HasGaps reports whether any object is missing or has the wrong size
+func (r Report) HasGaps() bool {
+	return len(r.Missing) > 0 || len(r.SizeMismatch) > 0
+}
+
+type verifyJob struct {
+	repoName string
+	target   common.Target
+	record   common.InventoryRecord
+}
+
+// VerifyFromCSV checks that every LFS object referenced by the local clones
+// of the inventory's repositories exists on the target
+func VerifyFromCSV() error {
+	records, err := common.ReadInventory(viper.GetString("GHMLFS_FILE"))
+	if err != nil {
+		return err
+	}
+
+	return VerifyRecords(records)
+}
+
+// VerifyRecords checks the LFS objects of an inventory's repositories on
+// their targets
+func VerifyRecords(records []common.InventoryRecord) error {
+	mappingFile := viper.GetString("GHMLFS_MAPPING_FILE")
+	workDir := viper.GetString("GHMLFS_WORK_DIR")
+	targetOrg := viper.GetString("GHMLFS_TARGET_ORGANIZATION")
+	hostname := viper.GetString("GHMLFS_TARGET_HOSTNAME")
+	token := viper.GetString("GHMLFS_TARGET_TOKEN")
+	maxWorkers := viper.GetInt("GHMLFS_WORKERS")
+	common.RegisterSecrets(token)
+	common.ConfigureRepoLogs(workDir)
+
+	store, mode, err := state.OpenForRun(workDir)
+	if err != nil {
+		return err
+	}
+	records = store.Select(records, state.PhaseVerified, mode)
+
+	mappings, err := common.LoadMappings(mappingFile)
+	if err != nil {
+		return err
+	}
+
+	var verifyJobs []verifyJob
+	for _, record := range records {
+		target, err := common.ResolveTarget(record, mappings, targetOrg)
+		if err != nil {
+			return fmt.Errorf("failed to resolve target for %s: %w", record.Repository, err)
+		}
+		verifyJobs = append(verifyJobs, verifyJob{repoName: record.Repository, target: target, record: record})
+	}
+
+	if viper.GetBool("GHMLFS_DRY_RUN") {
+		return planVerify(verifyJobs, workDir)
+	}
+
+	jobs := make(chan verifyJob)
+	go func() {
+		defer close(jobs)
+		for _, job := range verifyJobs {
+			jobs <- job
+		}
+	}()
+
+	var mu sync.Mutex
+	var reports []Report
+	stats := common.NewProcessStats()
+	stats.Expect(records)
+	process := func(job verifyJob) error {
+		return store.Track(job.repoName, state.PhaseVerified, func() error {
+			report, err := VerifyRepository(job.repoName, filepath.Join(workDir, job.repoName), nil, job.target, hostname, token)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			reports = append(reports, report)
+			mu.Unlock()
+
+			if report.HasGaps() {
+				return fmt.Errorf("%s: %w", job.repoName, ErrGaps)
+			}
+			return nil
+		})
+	}
+	poolErr := common.WorkerPool(jobs, maxWorkers, stats, func(job verifyJob) error {
+		return stats.Track(job.record, state.PhaseVerified, func() error {
+			return process(job)
+		})
+	})
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Repository < reports[j].Repository
+	})
+	PrintReports(reports)
+
+	reportPath := filepath.Join(workDir, ReportFile)
+	if err := WriteReports(reportPath, reports); err != nil {
+		return err
+	}
+
+	stats.PrintSummary(workDir)
+	common.Printf("📄 Verification report: %s\n", reportPath)
+	if err := stats.WriteResults(workDir, "verify"); err != nil {
+		return err
+	}
+
+	// Only gaps are reported as such, other failures keep their own error
+	if poolErr != nil {
+		gaps := 0
+		for _, r := range reports {
+			if r.HasGaps() {
+				gaps++
+			}
+		}
+		if gaps > 0 {
+			return fmt.Errorf("%w in %d repositories: %v", ErrGaps, gaps, poolErr)
+		}
+		return poolErr
+	}
+
+	common.Println("\n✅ Verification completed successfully!")
+	return nil
+}
+
+// VerifyRepository asks the target's LFS endpoint about every object
+// reachable from refs in the local clone at repoPath, or from all of its refs
+// when refs is empty
+func VerifyRepository(repoName, repoPath string, refs []string, target common.Target, hostname, token string) (Report, error) {
+	report := Report{Repository: repoName, Target: target.String()}
+
+	if _, err := os.Stat(repoPath); err != nil {
+		return report, fmt.Errorf("❌ Local clone of %s not found: %w", repoName, err)
+	}
+
+	pointers, err := lfs.ScanPointers(repoPath, refs)
+	if err != nil {
+		return report, fmt.Errorf("❌ Failed to scan LFS pointers of %s: %w", repoName, err)
+	}
+
+	return VerifyPointers(repoName, pointers, target, hostname, token)
+}
+
+// VerifyPointers asks the target's LFS endpoint about the objects of
+// pointers that were found without a local clone
+func VerifyPointers(repoName string, pointers []lfs.Pointer, target common.Target, hostname, token string) (Report, error) {
+	report := Report{Repository: repoName, Target: target.String()}
+
+	pointers = lfs.UniquePointers(pointers)
+	if len(pointers) == 0 {
+		return report, nil
+	}
+
+	specs := make([]lfs.ObjectSpec, len(pointers))
+	byOID := make(map[string]lfs.Pointer, len(pointers))
+	for i, p := range pointers {
+		specs[i] = lfs.ObjectSpec{OID: p.OID, Size: p.Size}
+		byOID[p.OID] = p
+	}
+
+	client := lfs.NewClient(lfs.EndpointForRepo(target.CloneURL(hostname)), token)
+	response, err := client.Batch(lfs.OperationDownload, specs)
+	if err != nil {
+		return report, fmt.Errorf("❌ Failed to query LFS objects of %s: %w", target, err)
+	}
+
+	answered := make(map[string]bool)
+	for _, obj := range response.Objects {
+		p, ok := byOID[obj.OID]
+		if !ok {
+			continue
+		}
+		answered[obj.OID] = true
+
+		switch {
+		case obj.Error != nil && obj.Error.Code == http.StatusUnprocessableEntity:
+			// The server rejects requests whose size differs from the stored object
+			report.SizeMismatch = append(report.SizeMismatch, p)
+		case obj.Error != nil || obj.Actions["download"] == nil:
+			report.Missing = append(report.Missing, p)
+		case obj.Size != p.Size:
+			report.SizeMismatch = append(report.SizeMismatch, p)
+		default:
+			report.Present = append(report.Present, p)
+		}
+	}
+
+	// Objects the server didn't answer for can't be assumed present
+	for _, p := range pointers {
+		if !answered[p.OID] {
+			report.Missing = append(report.Missing, p)
+		}
+	}
+
+	return report, nil
+}
+
+// PrintReports prints a per-repository table followed by the objects that
+// are missing or mismatched
+func PrintReports(reports []Report) {
+	if len(reports) == 0 {
+		return
+	}
+
+	data := pterm.TableData{{"Repository", "Target", "Expected", "Present", "Missing", "Size mismatch"}}
+	for _, r := range reports {
+		data = append(data, []string{
+			r.Repository,
+			r.Target,
+			strconv.Itoa(r.Expected()),
+			strconv.Itoa(len(r.Present)),
+			strconv.Itoa(len(r.Missing)),
+			strconv.Itoa(len(r.SizeMismatch)),
+		})
+	}
+	common.Println()
+	pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+
+	for _, r := range reports {
+		printObjects(r.Repository, "missing", r.Missing)
+		printObjects(r.Repository, "size mismatch", r.SizeMismatch)
+	}
+}
+
+func printObjects(repoName, label string, pointers []lfs.Pointer) {
+	for i, p := range pointers {
+		if i == maxListedObjects {
+			common.Printf("   ... and %d more\n", len(pointers)-maxListedObjects)
+			break
+		}
+		common.Printf("❌ %s %s: %s %s (%s)\n", repoName, label, p.OID, p.Path, p.Ref)
+	}
+}
+
+// WriteReports writes one row per missing or mismatched object, and one
+// summary row per repository with no OID
+func WriteReports(filename string, reports []Report) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating report file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"Repository", "Target", "Status", "Expected", "Present", "Missing", "SizeMismatch", "OID", "Size", "Path", "Ref"}); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	for _, r := range reports {
+		status := "ok"
+		if r.HasGaps() {
+			status = "gaps"
+		}
+		rows := [][]string{{
+			r.Repository, r.Target, status,
+			strconv.Itoa(r.Expected()), strconv.Itoa(len(r.Present)),
+			strconv.Itoa(len(r.Missing)), strconv.Itoa(len(r.SizeMismatch)),
+			"", "", "", "",
+		}}
+		for _, p := range r.Missing {
+			rows = append(rows, objectRow(r, "missing", p))
+		}
+		for _, p := range r.SizeMismatch {
+			rows = append(rows, objectRow(r, "size-mismatch", p))
+		}
+		for _, row := range rows {
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("error writing report: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func objectRow(r Report, status string, p lfs.Pointer) []string {
+	return []string{r.Repository, r.Target, status, "", "", "", "", p.OID, strconv.FormatInt(p.Size, 10), p.Path, p.Ref}
+}